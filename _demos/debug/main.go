@@ -4,6 +4,7 @@ It prints an underlined "Welcome"
 to roughly the center of the terminal.
 and it displays key pressed, keycodes,
 and mouse events received in the top left.
+Ctrl-Z suspends it, and Ctrl-E opens $EDITOR.
 */
 package main
 
@@ -11,6 +12,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 
 	"github.com/pzl/tui"
 	"github.com/pzl/tui/ansi"
@@ -27,19 +29,27 @@ func main() {
 
 	motion := ansi.MotionAll
 
-	defer func() {
+	setup := func() {
+		buf.Screen(ansi.Alt)
+		buf.MoveTo(x, y)
+		buf.CursorHide()
+		buf.MouseEnable(motion)
+		buf.MouseSGR(true)
+		buf.FocusReporting(true)
+		buf.Style(ansi.Underline)
+
+		fmt.Printf(string(ansi.Underline) + msg + ansi.Style(ansi.Reset))
+	}
+	teardown := func() {
+		buf.FocusReporting(false)
+		buf.MouseSGR(false)
 		buf.MouseDisable(motion)
 		buf.Screen(ansi.Normal)
 		buf.CursorShow()
-	}()
-
-	buf.Screen(ansi.Alt)
-	buf.MoveTo(x, y)
-	buf.CursorHide()
-	buf.MouseEnable(motion)
-	buf.Style(ansi.Underline)
+	}
 
-	fmt.Printf(string(ansi.Underline) + msg + ansi.Style(ansi.Reset))
+	defer teardown()
+	setup()
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -58,20 +68,61 @@ func main() {
 		i++
 		switch ev.Type {
 		case tui.KeyPrint:
-			fmt.Printf("%06d %s", i, string(ev.Key))
+			fmt.Printf("%06d %s%s", i, string(ev.Key), keyInfo(ev))
 		case tui.KeySpecial:
-			fmt.Printf("%06d %s", i, keyMap[ev.Key])
+			fmt.Printf("%06d %s%s", i, keyMap[ev.Key], keyInfo(ev))
 			if ev.Key == tui.CtrlC || ev.Key == tui.ESC {
 				return
 			}
+			if ev.Key == tui.CtrlZ {
+				teardown()
+				tui.Suspend()
+			}
+			if ev.Key == tui.CtrlE {
+				editor := os.Getenv("EDITOR")
+				if editor == "" {
+					editor = "vi"
+				}
+				teardown()
+				tui.Exec(exec.Command(editor))
+			}
 		case tui.EventInvalid:
 			fmt.Printf("%06d invalid ev %v", i, ev.M)
 		case tui.Mouse:
 			fmt.Printf("%06d Mouse %v", i, ev.M)
+		case tui.Paste:
+			fmt.Printf("%06d Paste %q", i, ev.Text)
+		case tui.FocusIn:
+			fmt.Printf("%06d Focus in", i)
+		case tui.FocusOut:
+			fmt.Printf("%06d Focus out", i)
+		case tui.Resize:
+			fmt.Printf("%06d Resize %dx%d", i, ev.Width, ev.Height)
+		case tui.Resume:
+			setup()
+			buf.Origin()
+			fmt.Printf("%06d Resume %dx%d", i, ev.Width, ev.Height)
+		case tui.Reply:
+			fmt.Printf("%06d Reply %q", i, ev.Text)
 		}
 	}
 }
 
+// modifiers and key action, when the terminal reports them
+func keyInfo(ev tui.Event) string {
+	s := ""
+	if ev.Mod != 0 {
+		s += " mod:" + ev.Mod.String()
+	}
+	switch ev.Action {
+	case tui.KeyRepeat:
+		s += " (repeat)"
+	case tui.KeyRelease:
+		s += " (release)"
+	}
+	return s
+}
+
 var keyMap = map[rune]string{
 	tui.Null:            "Null",
 	tui.CtrlA:           "CtrlA",
@@ -215,6 +266,8 @@ var keyMap = map[rune]string{
 	tui.Home:  "Home",
 	tui.End:   "End",
 
+	tui.Insert: "Insert",
+
 	// relative order of the next 8 matter
 	tui.SUp:    "SUp",
 	tui.SDown:  "SDown",
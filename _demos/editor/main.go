@@ -4,7 +4,7 @@ It is a gross half-breed of nano and vim.
 It's almost entirely nano, but ESC puts you in command mode
 where you can move with arrows OR hjkl.
 i for insert mode, where you can just type as normal like nano.
-Paste seems to work.
+Paste works, via bracketed paste mode. Ctrl-Z suspends, like any other program.
 Scrolling does *NOT*. So don't write more lines than you have terminal lines.
 It also doesn't save or read anything. It's just a UI scratch pad.
 */
@@ -12,10 +12,9 @@ package main
 
 import (
 	"bytes"
-	"context"
 	"fmt"
-	"os"
 	"strings"
+	"time"
 
 	"github.com/pzl/tui"
 	"github.com/pzl/tui/ansi"
@@ -41,16 +40,15 @@ const (
 )
 
 type Editor struct {
-	scr         *ansi.Writer
-	mode        EditMode
-	buf         [][]byte
-	curLine     int
-	curX        int
-	w           int
-	h           int
-	done        chan struct{}
-	inputDone   func()
-	restoreTerm func() error
+	term    *tui.Terminal
+	scr     *ansi.Writer
+	mode    EditMode
+	buf     [][]byte
+	curLine int
+	curX    int
+	w       int
+	h       int
+	done    chan struct{}
 }
 
 func NewEditor() *Editor {
@@ -60,7 +58,6 @@ func NewEditor() *Editor {
 	buf[0] = newline()
 
 	return &Editor{
-		scr:     ansi.NewWriter(nil),
 		mode:    ModeCommand,
 		curLine: 0,
 		curX:    0,
@@ -72,21 +69,23 @@ func NewEditor() *Editor {
 }
 
 func (e *Editor) Start() error {
-	ctx, cancel := context.WithCancel(context.Background())
-	e.inputDone = cancel
-	events, restore, err := tui.GetInput(ctx, int(os.Stdin.Fd()))
-	e.restoreTerm = restore
+	term, err := tui.Open(
+		tui.AltScreen(),
+		tui.MouseReporting(MOTION),
+		tui.BracketedPaste(),
+		tui.CursorStyle(ansi.CursorBlinker),
+		tui.InputOptions(tui.EscTimeout(25*time.Millisecond)), // ESC switches modes, keep it snappy
+	)
 	if err != nil {
 		return err
 	}
-
-	e.scr.Screen(ansi.Alt)
+	e.term = term
+	e.scr = term.Writer
 	e.scr.Origin()
-	e.scr.MouseEnable(MOTION)
 
 	for {
 		select {
-		case ev := <-events:
+		case ev := <-term.Events():
 			e.handle(ev)
 			e.Redraw()
 		case <-e.done:
@@ -116,20 +115,23 @@ func (e *Editor) Redraw() {
 }
 
 func (e *Editor) Cleanup() {
-	if e.inputDone != nil {
-		e.inputDone()
-	}
-	if e.restoreTerm != nil {
-		e.restoreTerm()
+	if e.term != nil {
+		e.term.Close()
 	}
-
-	e.scr.MouseDisable(MOTION)
-	e.scr.Screen(ansi.Normal)
-	e.scr.CursorBlinker()
-	e.scr.CursorShow()
 }
 
 func (e *Editor) handle(ev tui.Event) {
+	if ev.Type == tui.Resize || ev.Type == tui.Resume {
+		e.w, e.h = ev.Width, ev.Height
+		return
+	}
+	if ev.Type == tui.Paste {
+		if e.mode == ModeInsert {
+			e.paste(ev.Text)
+		}
+		return
+	}
+
 	// same regardless of mode
 	switch ev.Key {
 	case tui.ESC:
@@ -139,6 +141,9 @@ func (e *Editor) handle(ev tui.Event) {
 	case tui.CtrlC:
 		close(e.done)
 		return
+	case tui.CtrlZ:
+		e.term.Suspend()
+		return
 	case tui.Up, tui.Down, tui.Left, tui.Right:
 		e.move(ev.Key)
 		return
@@ -148,20 +153,7 @@ func (e *Editor) handle(ev tui.Event) {
 	case ModeInsert:
 		switch ev.Key {
 		case tui.CtrlJ, tui.CtrlM:
-			if e.curX == 0 && len(e.buf[e.curLine]) != 0 { // sitting at the start of a non-blank line
-				e.insertLine(newline(), e.curLine)
-			} else if e.atEOL() { // simple add a line
-				e.insertLine(newline(), e.curLine+1)
-				e.curX = 0
-			} else { // enter in the middle of a line
-				// add new line with existing content
-				n := make([]byte, len(e.buf[e.curLine][e.curX:]))
-				copy(n, e.buf[e.curLine][e.curX:])
-				e.insertLine(n, e.curLine+1)
-				e.buf[e.curLine] = e.buf[e.curLine][:e.curX] // truncate current line
-				e.curX = 0
-			}
-			e.curLine++
+			e.enter()
 		case tui.Tab:
 			e.insertBytes([]byte("  ")) // bake-in 2-spaces as tab?
 		case tui.BSpace:
@@ -202,6 +194,34 @@ func (e *Editor) handle(ev tui.Event) {
 	}
 }
 
+func (e *Editor) enter() {
+	if e.curX == 0 && len(e.buf[e.curLine]) != 0 { // sitting at the start of a non-blank line
+		e.insertLine(newline(), e.curLine)
+	} else if e.atEOL() { // simple add a line
+		e.insertLine(newline(), e.curLine+1)
+		e.curX = 0
+	} else { // enter in the middle of a line
+		// add new line with existing content
+		n := make([]byte, len(e.buf[e.curLine][e.curX:]))
+		copy(n, e.buf[e.curLine][e.curX:])
+		e.insertLine(n, e.curLine+1)
+		e.buf[e.curLine] = e.buf[e.curLine][:e.curX] // truncate current line
+		e.curX = 0
+	}
+	e.curLine++
+}
+
+// pasted text is inserted as-is. Newlines split lines, instead of acting like key presses
+func (e *Editor) paste(text string) {
+	text = strings.NewReplacer("\r\n", "\n", "\r", "\n").Replace(text)
+	for i, line := range strings.Split(text, "\n") {
+		if i > 0 {
+			e.enter()
+		}
+		e.insertBytes([]byte(strings.Replace(line, "\t", "  ", -1)))
+	}
+}
+
 func (e *Editor) move(key rune) {
 	switch key {
 	case tui.Up, 'k':
@@ -1,6 +1,7 @@
 package ansi
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io"
 	"os"
@@ -15,21 +16,31 @@ const csi = "\x1b["
 The ansi.Writer is the primary use case for the ansi library. Text effects (including colors) can be accessed separately as strings. (see: `ansi.Efect()`)
 
 You must create a writer with ansi.NewWriter() prior to use. A nil argument is accepted, and a new ansi.Writer will be created using os.Stderr as the default output. Commands are issued to the provided io.Writer immediately. In some situations, this is undesired as a user may see the cursor flash around the screen. It may be preferred to buffer the output and write it all at once. You can accomplish that with bufio.
+
+Colors are brought down to what the output can show (see Profile). For an *os.File that's detected from the environment, and a file that isn't a terminal gets no colors at all. Any other io.Writer, like a bufio.Writer, gets colors as they are. Either can be changed with SetProfile.
 */
 type Writer struct {
-	w io.Writer
+	w       io.Writer
+	profile Profile
 }
 
-// Creates an ansi.Writer that will use the provided io.Writer. If nil is provided, Stderr is used. This could be any writer, however: Stdout if you prefer that over Stderr. It could be a file (if you want ansi sequences in them). A stream, network, whatever.
+// Creates an ansi.Writer that will use the provided io.Writer. If nil is provided, Stderr is used. This could be any writer, however: Stdout if you prefer that over Stderr. A file, a stream, network, whatever. A file that isn't a terminal gets no colors by default, see SetProfile.
 func NewWriter(w io.Writer) *Writer {
 	if w == nil {
 		w = os.Stderr
 	}
-	return &Writer{w: w}
+	return &Writer{w: w, profile: DetectProfile(w)}
 }
 
+// Sets how many colors the output can show, in place of the detected Profile
+func (w *Writer) SetProfile(p Profile) { w.profile = p }
+func (w *Writer) Profile() Profile     { return w.profile }
+
 func (w *Writer) csi(s string) { w.write(csi + s) }
 
+// operating system commands, ended with ST. (BEL would be stripped by write)
+func (w *Writer) osc(s string) { w.write("\x1b]" + s + "\x1b\\") }
+
 func (w *Writer) write(s string) {
 	// handle non-displayable chars
 	bytes := []byte(s)
@@ -109,7 +120,26 @@ func Effect(t ...textEffect) string {
 	return csi + strings.Join(s, ";") + "m"
 }
 
-func (w *Writer) Effect(t ...textEffect) { w.write(Effect(t...)) }
+// Like Effect, with the colors brought down to the Writer's Profile
+func (w *Writer) Effect(t ...textEffect) {
+	if len(t) == 0 {
+		w.write(Effect())
+		return
+	}
+	kept := make([]textEffect, 0, len(t))
+	for _, e := range t {
+		if c, ok := e.(colorable); ok {
+			if c = w.profile.Convert(c); c.effect() == "" {
+				continue
+			}
+			e = c
+		}
+		kept = append(kept, e)
+	}
+	if len(kept) > 0 { // or else they were all colors, and there's nothing to do
+		w.write(Effect(kept...))
+	}
+}
 
 // styles, e.g. bold, underline, blink
 type TextStyle int
@@ -169,8 +199,68 @@ func getMotion(m MouseMotion) string {
 func (w *Writer) MouseEnable(m MouseMotion)  { w.csi(getMotion(m) + "h") }
 func (w *Writer) MouseDisable(m MouseMotion) { w.csi(getMotion(m) + "l") }
 
-// Color provider. Either the basic 16 colors, or 8-bit and 24-bit-truecolor
-type colorable interface{ color() string }
+// Switches SGR extended mouse reporting (1006) on or off. Use together with MouseEnable.
+// Coordinates are no longer limited to 223 columns/rows, and releases report which button was let go
+func (w *Writer) MouseSGR(on bool) { w.csi("?1006" + setReset(on)) }
+
+// Switches bracketed paste mode (2004) on or off. Pasted text is then wrapped in markers,
+// so it can be told apart from typing
+func (w *Writer) BracketedPaste(on bool) { w.csi("?2004" + setReset(on)) }
+
+// Switches focus reporting (1004) on or off. The terminal then reports when its window gains or loses focus
+func (w *Writer) FocusReporting(on bool) { w.csi("?1004" + setReset(on)) }
+
+// Progressive enhancement flags for the kitty keyboard protocol.
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/
+type KeyboardFlags int
+
+const (
+	KeyDisambiguate     KeyboardFlags = 1 << iota // send escape codes for keys that are otherwise ambiguous, e.g. Ctrl-I vs Tab
+	KeyReportEvents                               // also report key repeat and release
+	KeyReportAlternates                           // include the shifted version of keys
+	KeyReportAll                                  // send every key as an escape code, even plain text
+	KeyReportText                                 // include the text a key produces
+)
+
+// Enables the kitty keyboard protocol with the given flags. The previous flags are pushed onto a stack
+// in the terminal, to be restored with KittyKeyboardPop
+func (w *Writer) KittyKeyboard(f KeyboardFlags) { w.csi(">" + strconv.Itoa(int(f)) + "u") }
+func (w *Writer) KittyKeyboardPop()             { w.csi("<u") }
+
+// Sets xterm's modifyOtherKeys level. At level 2, keys combined with modifiers are sent as escape codes,
+// including combinations that otherwise collapse into control codes, like Ctrl-Shift-a or Ctrl-1.
+// Level 0 turns it off
+func (w *Writer) ModifyOtherKeys(level int) { w.csi(">4;" + strconv.Itoa(level) + "m") }
+
+// A clipboard to use with OSC 52
+type Selection byte
+
+const (
+	Clipboard Selection = 'c'
+	Primary   Selection = 'p' // the X11 primary selection, what middle-click pastes
+)
+
+// Copies data to the terminal's clipboard (OSC 52). This goes through the terminal, so it works over ssh.
+// Some terminals turn it off, or limit how much can be copied
+func (w *Writer) SetClipboard(s Selection, data string) {
+	w.osc("52;" + string(s) + ";" + base64.StdEncoding.EncodeToString([]byte(data)))
+}
+
+// h or l, to set or reset a terminal mode
+func setReset(on bool) string {
+	if on {
+		return "h"
+	}
+	return "l"
+}
+
+// Color provider. Either the basic 16 colors, or 8-bit and 24-bit-truecolor.
+// Any of them can be made a background color with Bg
+type colorable interface {
+	color() string
+	effect() string   // as a foreground color
+	bgEffect() string // as a background color
+}
 
 // Returns the escape sequence string for the given color
 func Color(c colorable) string { return c.color() }
@@ -189,23 +279,41 @@ const (
 	White
 )
 
+// the bright versions, which most terminals also show in place of bold
+const (
+	BrightBlack BasicColor = 90 + iota
+	BrightRed
+	BrightGreen
+	BrightYellow
+	BrightBlue
+	BrightMagenta
+	BrightCyan
+	BrightWhite
+)
+
+// the terminal's own foreground, or background with Bg
+const DefaultColor BasicColor = 39
+
 // support for using BasicColor directly with %s specifier
-func (c BasicColor) String() string { return csi + c.effect() + "m" }
-func (c BasicColor) color() string  { return c.String() }           // colorable
-func (c BasicColor) effect() string { return strconv.Itoa(int(c)) } // texteffect
+func (c BasicColor) String() string   { return csi + c.effect() + "m" }
+func (c BasicColor) color() string    { return c.String() }                // colorable
+func (c BasicColor) effect() string   { return strconv.Itoa(int(c)) }      // texteffect
+func (c BasicColor) bgEffect() string { return strconv.Itoa(int(c) + 10) } // 40-47, 49, 100-107
 
-func (w *Writer) Color(c colorable) { w.write(c.color()) }
+// Sets the color, brought down to the Writer's Profile
+func (w *Writer) Color(c colorable) { w.write(w.profile.Convert(c).color()) }
 
-//func (w *Writer) ColorBasicBg(b BasicColor) { w.csi(strconv.Itoa(int(b)+10) + "m") }
+// Sets the background color. Same as Color(Bg(c))
+func (w *Writer) Background(c colorable) { w.Color(Bg(c)) }
 
 // Eight bit color. first 16 identical to BasicColor. 216 colors as color cube. 24 greyscale
 // https://en.wikipedia.org/wiki/ANSI_escape_code#8-bit
 type EBColor uint8
 
-func (e EBColor) String() string { return csi + e.effect() }
-func (e EBColor) color() string  { return e.String() }                           //colorable
-func (e EBColor) effect() string { return "38;5;" + strconv.Itoa(int(e)) + "m" } //texteffect
-// ^ background is 48
+func (e EBColor) String() string   { return csi + e.effect() + "m" }
+func (e EBColor) color() string    { return e.String() }                     //colorable
+func (e EBColor) effect() string   { return "38;5;" + strconv.Itoa(int(e)) } //texteffect
+func (e EBColor) bgEffect() string { return "48;5;" + strconv.Itoa(int(e)) }
 
 // 24-bit True Color rendering. Terminal support for this is spotty. And detection is VERY hard
 type TrueColor int
@@ -213,13 +321,30 @@ type TrueColor int
 // convenience function for creating a color with (r,g,b)
 func T(r uint8, g uint8, b uint8) TrueColor { return TrueColor(int(r)<<16 | int(g)<<8 | int(b)) }
 
-func (t TrueColor) String() string { return csi + t.effect() }
-func (t TrueColor) color() string  { return t.String() } //colorable
-func (t TrueColor) effect() string {
-	//bg is 48
-	return "38;2;" + strconv.Itoa(int(t)>>16) + ";" + strconv.Itoa(int(t)>>8&0xff) + ";" + strconv.Itoa(int(t)&0xff) + "m"
+func (t TrueColor) String() string   { return csi + t.effect() + "m" }
+func (t TrueColor) color() string    { return t.String() } //colorable
+func (t TrueColor) effect() string   { return "38;2;" + t.rgb() }
+func (t TrueColor) bgEffect() string { return "48;2;" + t.rgb() }
+func (t TrueColor) rgb() string {
+	return strconv.Itoa(int(t)>>16&0xff) + ";" + strconv.Itoa(int(t)>>8&0xff) + ";" + strconv.Itoa(int(t)&0xff)
 }
 
+// A color used as the background. Works anywhere the color itself does: Color, Effect, or with %s
+type BgColor struct{ c colorable }
+
+// Makes any color a background color, e.g. Effect(White, Bg(Blue))
+func Bg(c colorable) BgColor {
+	if b, ok := c.(BgColor); ok {
+		return b
+	}
+	return BgColor{c}
+}
+
+func (b BgColor) String() string   { return csi + b.effect() + "m" }
+func (b BgColor) color() string    { return b.String() }
+func (b BgColor) effect() string   { return b.c.bgEffect() }
+func (b BgColor) bgEffect() string { return b.c.bgEffect() }
+
 // Moving the Cursor around the terminal
 type CursorCmd string
 
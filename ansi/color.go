@@ -0,0 +1,218 @@
+package ansi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parses a hex color, as in CSS: "#ff8800", or the short "#f80". The # is optional
+func Hex(s string) (TrueColor, error) {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) == 3 {
+		h = string([]byte{h[0], h[0], h[1], h[1], h[2], h[2]})
+	}
+	if len(h) != 6 {
+		return 0, fmt.Errorf("not a hex color: %q", s)
+	}
+	v, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("not a hex color: %q", s)
+	}
+	return TrueColor(v), nil
+}
+
+// Looks up a CSS or X11 color name, like "cornflowerblue" or "Dark Slate Gray". Case and spaces don't matter.
+// X11's gray0 to gray100 (or grey) are there too
+func Named(name string) (TrueColor, bool) {
+	name = strings.ToLower(strings.Replace(name, " ", "", -1))
+	if c, ok := colorNames[name]; ok {
+		return c, true
+	}
+	for _, prefix := range []string{"gray", "grey"} {
+		if strings.HasPrefix(name, prefix) {
+			if n, err := strconv.Atoi(name[len(prefix):]); err == nil && n >= 0 && n <= 100 {
+				g := uint8(math.Round(float64(n) * 255 / 100))
+				return T(g, g, g), true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Parses a color the way a theme file might have it: a hex color (see Hex) or a name (see Named)
+func ParseColor(s string) (TrueColor, error) {
+	s = strings.TrimSpace(s)
+	if c, ok := Named(s); ok {
+		return c, nil
+	}
+	if c, err := Hex(s); err == nil {
+		return c, nil
+	}
+	return 0, fmt.Errorf("not a color: %q", s)
+}
+
+// The red, green and blue parts of t
+func (t TrueColor) RGB() (r, g, b uint8) { return uint8(t >> 16), uint8(t >> 8), uint8(t) }
+
+// How bright t is to the eye, 0 (black) to 1 (white): its relative luminance, as in WCAG
+func (t TrueColor) Luminance() float64 {
+	r, g, b := t.RGB()
+	return 0.2126*linear(r) + 0.7152*linear(g) + 0.0722*linear(b)
+}
+
+// What this color looks like: cube and grayscale colors are always the same, 0-15 are xterm's defaults,
+// as they vary with the terminal's theme
+func (e EBColor) TrueColor() TrueColor {
+	switch {
+	case e < 16:
+		return xterm16[e]
+	case e < 232:
+		n := int(e) - 16
+		return T(cubeLevel(n/36), cubeLevel(n/6%6), cubeLevel(n%6))
+	}
+	g := uint8(8 + 10*(int(e)-232))
+	return T(g, g, g)
+}
+
+// the levels of each of red, green and blue in the 6x6x6 cube
+func cubeLevel(n int) uint8 {
+	if n == 0 {
+		return 0
+	}
+	return uint8(55 + 40*n)
+}
+
+var xterm16 = [16]TrueColor{
+	0x000000, 0xcd0000, 0x00cd00, 0xcdcd00, 0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+	0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00, 0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+}
+
+// A color from hue (in degrees), saturation and lightness (0 to 1)
+func HSL(h, s, l float64) TrueColor {
+	s, l = clamp(s), clamp(l)
+	c := (1 - math.Abs(2*l-1)) * s
+	return fromHue(h, c, l-c/2)
+}
+
+// A color from hue (in degrees), saturation and value (0 to 1)
+func HSV(h, s, v float64) TrueColor {
+	s, v = clamp(s), clamp(v)
+	c := v * s
+	return fromHue(h, c, v-c)
+}
+
+// hue, chroma, and the amount added to every channel
+func fromHue(h, c, m float64) TrueColor {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return T(to8(r+m), to8(g+m), to8(b+m))
+}
+
+// t's hue (in degrees), saturation and lightness (0 to 1)
+func (t TrueColor) HSL() (h, s, l float64) {
+	max, min, h := t.hue()
+	l = (max + min) / 2
+	if max != min {
+		s = (max - min) / (1 - math.Abs(2*l-1))
+	}
+	return h, s, l
+}
+
+// t's hue (in degrees), saturation and value (0 to 1)
+func (t TrueColor) HSV() (h, s, v float64) {
+	max, min, h := t.hue()
+	if max > 0 {
+		s = (max - min) / max
+	}
+	return h, s, max
+}
+
+// the largest and smallest channels (0 to 1), and the hue
+func (t TrueColor) hue() (max, min, h float64) {
+	r8, g8, b8 := t.RGB()
+	r, g, b := float64(r8)/255, float64(g8)/255, float64(b8)/255
+	max, min = math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	d := max - min
+	switch {
+	case d == 0:
+		h = 0
+	case max == r:
+		h = 60 * math.Mod((g-b)/d, 6)
+	case max == g:
+		h = 60 * ((b-r)/d + 2)
+	default:
+		h = 60 * ((r-g)/d + 4)
+	}
+	if h < 0 {
+		h += 360
+	}
+	return max, min, h
+}
+
+// Makes t lighter by amount (0 to 1), on HSL's lightness. Lighten(1) is always white
+func (t TrueColor) Lighten(amount float64) TrueColor {
+	h, s, l := t.HSL()
+	return HSL(h, s, l+amount)
+}
+
+// Makes t darker by amount (0 to 1), on HSL's lightness. Darken(1) is always black
+func (t TrueColor) Darken(amount float64) TrueColor { return t.Lighten(-amount) }
+
+// Mixes c into t: none of it at amount 0, all of it at 1. Channel by channel, like a CSS color-mix in srgb
+func (t TrueColor) Blend(c TrueColor, amount float64) TrueColor {
+	amount = clamp(amount)
+	r1, g1, b1 := t.RGB()
+	r2, g2, b2 := c.RGB()
+	mix := func(x, y uint8) uint8 { return to8((float64(x) + (float64(y)-float64(x))*amount) / 255) }
+	return T(mix(r1, r2), mix(g1, g2), mix(b1, b2))
+}
+
+/*
+The color a fraction f (0 to 1) of the way from one color to another. Unlike Blend, this goes through
+L*a*b*, so the steps look even: the middle of a gradient doesn't come out muddy or too dark
+*/
+func Interpolate(from, to TrueColor, f float64) TrueColor {
+	f = clamp(f)
+	a, b := lab(from), lab(to)
+	return labColor{a.l + (b.l-a.l)*f, a.a + (b.a-a.a)*f, a.b + (b.b-a.b)*f}.rgb()
+}
+
+// n colors evenly spaced from one color to another, both included
+func Gradient(from, to TrueColor, n int) []TrueColor {
+	if n <= 0 {
+		return nil
+	}
+	if n == 1 {
+		return []TrueColor{from}
+	}
+	colors := make([]TrueColor, n)
+	for i := range colors {
+		colors[i] = Interpolate(from, to, float64(i)/float64(n-1))
+	}
+	return colors
+}
+
+func clamp(f float64) float64 { return math.Max(0, math.Min(1, f)) }
+
+// 0 to 1, to a rounded 0 to 255
+func to8(f float64) uint8 { return uint8(math.Round(clamp(f) * 255)) }
@@ -0,0 +1,169 @@
+package ansi
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHex(t *testing.T) {
+	for in, want := range map[string]TrueColor{
+		"#ff8800": T(0xff, 0x88, 0),
+		"ff8800":  T(0xff, 0x88, 0),
+		"#F80":    T(0xff, 0x88, 0),
+		"#000000": 0,
+		"#abcdef": T(0xab, 0xcd, 0xef),
+	} {
+		c, err := Hex(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, want, c, in)
+	}
+	for _, in := range []string{"", "#", "#ff88", "#ff88001", "#gg8800", "red", "#+f8800"} {
+		_, err := Hex(in)
+		assert.Error(t, err, in)
+	}
+}
+
+func TestNamed(t *testing.T) {
+	for in, want := range map[string]TrueColor{
+		"cornflowerblue":  T(0x64, 0x95, 0xed),
+		"Dark Slate Gray": T(0x2f, 0x4f, 0x4f),
+		"RED":             T(0xff, 0, 0),
+		"gray":            T(0x80, 0x80, 0x80),
+		"x11 gray":        T(0xbe, 0xbe, 0xbe),
+		"grey50":          T(0x80, 0x80, 0x80),
+		"gray0":           T(0, 0, 0),
+		"gray100":         T(0xff, 0xff, 0xff),
+	} {
+		c, ok := Named(in)
+		assert.True(t, ok, in)
+		assert.Equal(t, want, c, in)
+	}
+	for _, in := range []string{"", "notacolor", "gray101", "gray-1", "#ff0000"} {
+		_, ok := Named(in)
+		assert.False(t, ok, in)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor(" #f80 ")
+	assert.NoError(t, err)
+	assert.Equal(t, T(0xff, 0x88, 0), c)
+
+	c, err = ParseColor("tomato")
+	assert.NoError(t, err)
+	assert.Equal(t, T(0xff, 0x63, 0x47), c)
+
+	c, err = ParseColor("bad")
+	assert.NoError(t, err)
+	assert.Equal(t, T(0xbb, 0xaa, 0xdd), c, "short hex, since it isn't a name")
+
+	_, err = ParseColor("nope")
+	assert.Error(t, err)
+}
+
+func TestEBColor_TrueColor(t *testing.T) {
+	assert.Equal(t, T(0xcd, 0, 0), EBColor(1).TrueColor())
+	assert.Equal(t, T(0, 0, 0), EBColor(16).TrueColor())
+	assert.Equal(t, T(0, 0, 0x5f), EBColor(17).TrueColor())
+	assert.Equal(t, T(0xff, 0x87, 0), EBColor(208).TrueColor())
+	assert.Equal(t, T(0xff, 0xff, 0xff), EBColor(231).TrueColor())
+	assert.Equal(t, T(8, 8, 8), EBColor(232).TrueColor())
+	assert.Equal(t, T(0xee, 0xee, 0xee), EBColor(255).TrueColor())
+}
+
+func TestTrueColor_RGB(t *testing.T) {
+	r, g, b := T(1, 2, 3).RGB()
+	assert.Equal(t, [3]uint8{1, 2, 3}, [3]uint8{r, g, b})
+}
+
+func TestLuminance(t *testing.T) {
+	assert.Equal(t, 0.0, T(0, 0, 0).Luminance())
+	assert.InDelta(t, 1, T(255, 255, 255).Luminance(), 1e-9)
+	assert.InDelta(t, 0.2126, T(255, 0, 0).Luminance(), 1e-9)
+	assert.InDelta(t, 0.216, T(0x80, 0x80, 0x80).Luminance(), 1e-3)
+}
+
+func TestHSL(t *testing.T) {
+	tests := []struct {
+		h, s, l float64
+		c       TrueColor
+	}{
+		{0, 0, 0, T(0, 0, 0)},
+		{0, 0, 1, T(255, 255, 255)},
+		{0, 1, 0.5, T(255, 0, 0)},
+		{120, 1, 0.5, T(0, 255, 0)},
+		{240, 1, 0.5, T(0, 0, 255)},
+		{60, 1, 0.25, T(128, 128, 0)},
+		{210, 0.5, 0.5, T(64, 128, 191)},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.c, HSL(tc.h, tc.s, tc.l), "%v", tc)
+		h, s, l := tc.c.HSL()
+		assert.Equal(t, tc.c, HSL(h, s, l), "round trip %v", tc)
+	}
+	assert.Equal(t, HSL(30, 1, 0.5), HSL(390, 1, 0.5), "hue wraps around")
+	assert.Equal(t, HSL(330, 1, 0.5), HSL(-30, 1, 0.5))
+}
+
+func TestHSV(t *testing.T) {
+	assert.Equal(t, T(255, 0, 0), HSV(0, 1, 1))
+	assert.Equal(t, T(0, 128, 128), HSV(180, 1, 0.5))
+	assert.Equal(t, T(128, 128, 128), HSV(0, 0, 0.5))
+
+	h, s, v := T(255, 136, 0).HSV()
+	assert.InDelta(t, 32, h, 0.1)
+	assert.Equal(t, 1.0, s)
+	assert.Equal(t, 1.0, v)
+	assert.Equal(t, T(255, 136, 0), HSV(h, s, v))
+}
+
+func TestLightenDarken(t *testing.T) {
+	c := HSL(200, 0.6, 0.4)
+	_, _, l := c.Lighten(0.2).HSL()
+	assert.InDelta(t, 0.6, l, 0.01)
+	_, _, l = c.Darken(0.2).HSL()
+	assert.InDelta(t, 0.2, l, 0.01)
+
+	assert.Equal(t, T(255, 255, 255), c.Lighten(1))
+	assert.Equal(t, T(0, 0, 0), c.Darken(1))
+}
+
+func TestBlend(t *testing.T) {
+	black, white := T(0, 0, 0), T(255, 255, 255)
+	assert.Equal(t, black, black.Blend(white, 0))
+	assert.Equal(t, white, black.Blend(white, 1))
+	assert.Equal(t, T(128, 128, 128), black.Blend(white, 0.5))
+	assert.Equal(t, T(255, 0, 0), T(255, 0, 0).Blend(white, -1), "amount is clamped")
+}
+
+func TestInterpolate(t *testing.T) {
+	red, blue := T(255, 0, 0), T(0, 0, 255)
+	assert.Equal(t, red, Interpolate(red, blue, 0))
+	assert.Equal(t, blue, Interpolate(red, blue, 1))
+
+	// perceptually, the middle is half way in lightness
+	mid := lab(Interpolate(T(0, 0, 0), T(255, 255, 255), 0.5))
+	assert.InDelta(t, 50, mid.l, 0.5)
+}
+
+func TestGradient(t *testing.T) {
+	g := Gradient(T(0, 0, 0), T(255, 255, 255), 5)
+	require.Len(t, g, 5)
+	assert.Equal(t, T(0, 0, 0), g[0])
+	assert.Equal(t, T(255, 255, 255), g[4])
+	for i := 1; i < len(g); i++ {
+		assert.InDelta(t, 25, lab(g[i]).l-lab(g[i-1]).l, 0.5, "even steps")
+	}
+	assert.Len(t, Gradient(0, 0, 0), 0)
+	assert.Equal(t, []TrueColor{T(1, 2, 3)}, Gradient(T(1, 2, 3), 0, 1))
+}
+
+func TestLab_RoundTrip(t *testing.T) {
+	for _, c := range []TrueColor{0, 0xffffff, 0xff0000, 0x00ff00, 0x0000ff, 0x123456, 0xfedcba, 0x808080} {
+		assert.Equal(t, c, lab(c).rgb(), "%06x", int(c))
+	}
+	assert.True(t, math.Abs(lab(0xffffff).l-100) < 0.01)
+}
@@ -1,6 +1,12 @@
 /*
 ansi is a fairly simple wrapper around terminal escape codes.
 So you don't have to remember that \033[2J clears the screen.
+
+Colors written through a Writer are brought down to what the output can show (see Profile), and left out
+altogether for NO_COLOR, TERM=dumb, or a file that isn't a terminal.
+
+Strip, Width, Truncate, Pad and Wrap lay out text that already has escape codes in it, by the columns it
+takes up on screen.
 */
 package ansi
 
@@ -36,7 +42,8 @@ u - restore position
 -- 3/4-bit color (8,16 colors)
 3<n>m -- blk,r,g,y,bl,mag,cy,w
 3<n>;1m - bold version (or 9<n> for bright)
-4<n>m - background
+4<n>m - background (10<n> for bright)
+39m, 49m - default fg, bg
 
 -- 8-bit color (256 colors)
 38;5;<n>m - first 16 as above, then look up table
@@ -61,4 +68,17 @@ u - restore position
 
 ?1000h - enable mouse
 ?1000l - disable mouse
+?1006h - SGR extended mouse coordinates
+?1006l - back to X10 mouse coordinates
+
+?1004h - enable focus in/out reporting
+?1004l - disable focus in/out reporting
+
+><flags>u - push kitty keyboard protocol flags
+<u - pop kitty keyboard protocol flags
+
+>4;<n>m - xterm modifyOtherKeys level n
+
+?2004h - enable bracketed paste
+?2004l - disable bracketed paste
 */
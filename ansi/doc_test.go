@@ -68,6 +68,18 @@ func ExampleColor_trueColor() {
 	// Output: [38;2;196;86;121mAlso Red
 }
 
+func ExampleBg() {
+	fmt.Printf("%sWhite on Blue%s", ansi.Effect(ansi.White, ansi.Bg(ansi.Blue)), ansi.Reset)
+	// Output: [37;44mWhite on Blue[0m
+}
+
+func ExamplePen() {
+	bar := ansi.Pen{}.Foreground(ansi.Cyan)
+	done := ansi.Pen{}.Foreground(ansi.Green)
+	fmt.Print(bar.Render("[" + done.Render("===>") + "   ]"))
+	// Output: [36m[[32m===>[0m[36m   ][0m
+}
+
 func ExampleBasicColor_String_printf() {
 	fmt.Printf("%sThis is Red%sNow Blue%s", ansi.Red, ansi.Blue, ansi.Reset)
 	// Output: [31mThis is Red[34mNow Blue[0m
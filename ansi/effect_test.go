@@ -49,6 +49,42 @@ func TestColor_24bit(t *testing.T) {
 	assert.Equal(t, "\x1b[38;2;254;171;39m", Color(TrueColor(0xfeab27)))
 }
 
+func TestColor_Bright(t *testing.T) {
+	assert.Equal(t, "\x1b[91m", Color(BrightRed))
+	assert.Equal(t, "\x1b[97m", Color(BrightWhite))
+}
+
+func TestColor_Bg(t *testing.T) {
+	tests := map[string]struct {
+		c    colorable
+		want string
+	}{
+		"basic":   {c: Blue, want: "\x1b[44m"},
+		"bright":  {c: BrightBlack, want: "\x1b[100m"},
+		"default": {c: DefaultColor, want: "\x1b[49m"},
+		"8bit":    {c: EBColor(234), want: "\x1b[48;5;234m"},
+		"24bit":   {c: TrueColor(0xfeab27), want: "\x1b[48;2;254;171;39m"},
+		"twice":   {c: Bg(Blue), want: "\x1b[44m"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Color(Bg(tc.c)))
+			assert.Equal(t, tc.want, Bg(tc.c).String())
+		})
+	}
+}
+
+func TestEffect_Bg(t *testing.T) {
+	assert.Equal(t, "\x1b[37;44;1m", Effect(White, Bg(Blue), Bold))
+	assert.Equal(t, "\x1b[38;5;16;48;5;231m", Effect(EBColor(16), Bg(EBColor(231))))
+	assert.Equal(t, "\x1b[38;2;0;0;0;48;2;255;255;255m", Effect(TrueColor(0), Bg(TrueColor(0xffffff))))
+}
+
+func TestEffect_EightBitAndStyle(t *testing.T) {
+	assert.Equal(t, "\x1b[38;5;196;1m", Effect(EBColor(196), Bold))
+	assert.Equal(t, "\x1b[1;38;2;1;2;3m", Effect(Bold, T(1, 2, 3)))
+}
+
 // test that T can be used as an RGB shorthand
 func Test24bitColor_T(t *testing.T) {
 	assert.Equal(t, TrueColor(0xab12ce), T(0xab, 0x12, 0xce))
@@ -199,6 +199,80 @@ func TestMouseDisable(t *testing.T) {
 	}
 }
 
+func TestMouseSGR(t *testing.T) {
+	out, w := writer()
+	w.MouseSGR(true)
+	assert.Equal(t, "\x1b[?1006h", out.String())
+
+	out.Reset()
+	w.MouseSGR(false)
+	assert.Equal(t, "\x1b[?1006l", out.String())
+}
+
+func TestBracketedPaste(t *testing.T) {
+	out, w := writer()
+	w.BracketedPaste(true)
+	assert.Equal(t, "\x1b[?2004h", out.String())
+
+	out.Reset()
+	w.BracketedPaste(false)
+	assert.Equal(t, "\x1b[?2004l", out.String())
+}
+
+func TestFocusReporting(t *testing.T) {
+	out, w := writer()
+	w.FocusReporting(true)
+	assert.Equal(t, "\x1b[?1004h", out.String())
+
+	out.Reset()
+	w.FocusReporting(false)
+	assert.Equal(t, "\x1b[?1004l", out.String())
+}
+
+func TestKittyKeyboard(t *testing.T) {
+	out, w := writer()
+	w.KittyKeyboard(KeyDisambiguate | KeyReportEvents)
+	assert.Equal(t, "\x1b[>3u", out.String())
+
+	out.Reset()
+	w.KittyKeyboardPop()
+	assert.Equal(t, "\x1b[<u", out.String())
+}
+
+func TestModifyOtherKeys(t *testing.T) {
+	out, w := writer()
+	w.ModifyOtherKeys(2)
+	assert.Equal(t, "\x1b[>4;2m", out.String())
+
+	out.Reset()
+	w.ModifyOtherKeys(0)
+	assert.Equal(t, "\x1b[>4;0m", out.String())
+}
+
+func TestBackground(t *testing.T) {
+	out, w := writer()
+	w.Background(Red)
+	assert.Equal(t, "\x1b[41m", out.String())
+
+	out.Reset()
+	w.Background(T(1, 2, 3))
+	assert.Equal(t, "\x1b[48;2;1;2;3m", out.String())
+
+	out.Reset()
+	w.Color(Bg(EBColor(17)))
+	assert.Equal(t, "\x1b[48;5;17m", out.String())
+}
+
+func TestSetClipboard(t *testing.T) {
+	out, w := writer()
+	w.SetClipboard(Clipboard, "hello, world")
+	assert.Equal(t, "\x1b]52;c;aGVsbG8sIHdvcmxk\x1b\\", out.String())
+
+	out.Reset()
+	w.SetClipboard(Primary, "")
+	assert.Equal(t, "\x1b]52;p;\x1b\\", out.String())
+}
+
 func TestScreenModeAlt(t *testing.T) {
 	out, w := writer()
 	w.Screen(Alt)
@@ -0,0 +1,187 @@
+package ansi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/*
+A Pen holds a whole style: foreground and background colors, TextStyles like Bold, an underline color and a
+hyperlink. It's a value: every method returns a new Pen, and leaves the one it was called on alone. So
+Pens can be kept around, shared, and built from each other.
+
+	title := ansi.Pen{}.Foreground(ansi.White).Background(ansi.Blue).With(ansi.Bold)
+	fmt.Println(title.Render(" tui "))
+
+Where Effect lists codes once, a Pen keeps them, and Render wraps text in them. Anything already styled
+inside the text is fine: the Pen's style comes back after each reset in it.
+The zero Pen has no style at all.
+*/
+type Pen struct {
+	fg, bg, ul colorable // nil when not set
+	attrs      uint64    // a bit for each TextStyle
+	off        uint64    // TextStyles turned off with Without, so Merge and Inherit leave them off
+	link       string
+}
+
+// Sets the foreground color
+func (p Pen) Foreground(c colorable) Pen { p.fg = c; return p }
+
+// Sets the background color
+func (p Pen) Background(c colorable) Pen { p.bg = c; return p }
+
+// Sets the color of underlines, where the terminal supports it (SGR 58)
+func (p Pen) UnderlineColor(c colorable) Pen { p.ul = c; return p }
+
+// Makes the text a hyperlink to url (OSC 8)
+func (p Pen) Link(url string) Pen { p.link = url; return p }
+
+// Adds TextStyles, e.g. With(Bold, Underline)
+func (p Pen) With(ts ...TextStyle) Pen {
+	for _, t := range ts {
+		if t > Reset && t < 64 {
+			p.attrs |= 1 << uint(t)
+			p.off &^= 1 << uint(t)
+		}
+	}
+	return p
+}
+
+// Takes TextStyles away. They stay off through Merge and Inherit, even where the other Pen has them
+func (p Pen) Without(ts ...TextStyle) Pen {
+	for _, t := range ts {
+		if t > Reset && t < 64 {
+			p.attrs &^= 1 << uint(t)
+			p.off |= 1 << uint(t)
+		}
+	}
+	return p
+}
+
+/*
+p, with anything set on o put on top: o's colors and link where o has them, and o's TextStyles
+added (or taken away, with o.Without)
+*/
+func (p Pen) Merge(o Pen) Pen {
+	if o.fg != nil {
+		p.fg = o.fg
+	}
+	if o.bg != nil {
+		p.bg = o.bg
+	}
+	if o.ul != nil {
+		p.ul = o.ul
+	}
+	if o.link != "" {
+		p.link = o.link
+	}
+	p.attrs = p.attrs&^o.off | o.attrs
+	p.off = p.off&^o.attrs | o.off
+	return p
+}
+
+// p, with anything it doesn't set itself taken from parent. The same as parent.Merge(p)
+func (p Pen) Inherit(parent Pen) Pen { return parent.Merge(p) }
+
+// Brings p's colors down to a Profile. See Profile.Convert
+func (p Pen) For(pr Profile) Pen {
+	if p.fg != nil {
+		p.fg = pr.Convert(p.fg)
+	}
+	if p.bg != nil {
+		p.bg = pr.Convert(p.bg)
+	}
+	if p.ul != nil {
+		p.ul = pr.Convert(p.ul)
+	}
+	return p
+}
+
+// The SGR codes for p, without the CSI and m
+func (p Pen) effect() string {
+	var s []string
+	for t := uint(1); t < 64; t++ {
+		if p.attrs&(1<<t) != 0 {
+			s = append(s, strconv.Itoa(int(t)))
+		}
+	}
+	if p.fg != nil && p.fg.effect() != "" {
+		s = append(s, p.fg.effect())
+	}
+	if p.bg != nil && p.bg.bgEffect() != "" {
+		s = append(s, Bg(p.bg).effect())
+	}
+	if p.ul != nil {
+		if ul := underlineEffect(p.ul); ul != "" {
+			s = append(s, ul)
+		}
+	}
+	return strings.Join(s, ";")
+}
+
+// The escape sequence that switches to this Pen, e.g. to print with %s. Empty for a Pen with no style
+func (p Pen) String() string {
+	s := ""
+	if e := p.effect(); e != "" {
+		s = csi + e + "m"
+	}
+	if p.link != "" {
+		s += linkStart(p.link)
+	}
+	return s
+}
+
+/*
+Wraps s in the Pen's style, and resets after. Text in s that's already styled keeps its style: after
+each reset in s, this Pen's style is put back. Likewise for links in s
+*/
+func (p Pen) Render(s string) string {
+	start := p.String()
+	if start == "" {
+		return s
+	}
+	sgr := ""
+	if e := p.effect(); e != "" {
+		sgr = csi + e + "m"
+		s = strings.Replace(s, csi+"0m", csi+"0m"+sgr, -1)
+		s = strings.Replace(s, csi+"m", csi+"m"+sgr, -1)
+	}
+	end := ""
+	if sgr != "" {
+		end = csi + "0m"
+	}
+	if p.link != "" {
+		s = strings.Replace(s, linkEnd, linkStart(p.link), -1)
+		end = linkEnd + end
+	}
+	return start + s + end
+}
+
+// Writes s in Pen p, with the colors brought down to the Writer's Profile
+func (w *Writer) Render(p Pen, s string) { fmt.Fprint(w.w, p.For(w.profile).Render(s)) }
+
+const linkEnd = "\x1b]8;;\x1b\\"
+
+func linkStart(url string) string { return "\x1b]8;;" + url + "\x1b\\" }
+
+// SGR 58, which has no basic colors. Those are given as their place on the 256-color palette
+func underlineEffect(c colorable) string {
+	switch c := c.(type) {
+	case BgColor:
+		return underlineEffect(c.c)
+	case BasicColor:
+		switch {
+		case c == DefaultColor:
+			return "59"
+		case c >= BrightBlack:
+			return "58;5;" + strconv.Itoa(int(c-BrightBlack)+8)
+		}
+		return "58;5;" + strconv.Itoa(int(c-Black))
+	case EBColor:
+		return "58;5;" + strconv.Itoa(int(c))
+	case TrueColor:
+		return "58;2;" + c.rgb()
+	}
+	return "" // noColor
+}
@@ -0,0 +1,79 @@
+package ansi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPen_String(t *testing.T) {
+	assert.Equal(t, "", Pen{}.String())
+	assert.Equal(t, "\x1b[1;4;37;44m", Pen{}.Foreground(White).Background(Blue).With(Underline, Bold).String())
+	assert.Equal(t, "\x1b[38;5;208;48;2;1;2;3m", Pen{}.Foreground(EBColor(208)).Background(T(1, 2, 3)).String())
+	assert.Equal(t, "\x1b[44m", Pen{}.Background(Bg(Blue)).String(), "already a background")
+	assert.Equal(t, "\x1b[4;58;2;255;0;0m", Pen{}.With(Underline).UnderlineColor(T(255, 0, 0)).String())
+	assert.Equal(t, "\x1b[58;5;9m", Pen{}.UnderlineColor(BrightRed).String())
+	assert.Equal(t, "\x1b[58;5;2m", Pen{}.UnderlineColor(Green).String())
+	assert.Equal(t, "\x1b[59m", Pen{}.UnderlineColor(DefaultColor).String())
+	assert.Equal(t, "\x1b[2m", Pen{}.With(Dim, Reset).String(), "Reset isn't a style to keep")
+}
+
+func TestPen_Immutable(t *testing.T) {
+	base := Pen{}.Foreground(Red)
+	bold := base.With(Bold)
+	blue := base.Foreground(Blue)
+	assert.Equal(t, "\x1b[31m", base.String())
+	assert.Equal(t, "\x1b[1;31m", bold.String())
+	assert.Equal(t, "\x1b[34m", blue.String())
+}
+
+func TestPen_Render(t *testing.T) {
+	assert.Equal(t, "plain", Pen{}.Render("plain"))
+	assert.Equal(t, "\x1b[1;31mhi\x1b[0m", Pen{}.Foreground(Red).With(Bold).Render("hi"))
+}
+
+func TestPen_RenderNested(t *testing.T) {
+	outer := Pen{}.Foreground(Cyan)
+	inner := Pen{}.Foreground(Green)
+	s := outer.Render("[" + inner.Render("===>") + "]")
+	assert.Equal(t, "\x1b[36m[\x1b[32m===>\x1b[0m\x1b[36m]\x1b[0m", s, "cyan comes back after the inner reset")
+
+	s = outer.Render("a" + Reset.String() + "b" + Effect() + "c")
+	assert.Equal(t, "\x1b[36ma\x1b[0m\x1b[36mb\x1b[m\x1b[36mc\x1b[0m", s)
+}
+
+func TestPen_Link(t *testing.T) {
+	link := Pen{}.Link("https://example.com")
+	assert.Equal(t, "\x1b]8;;https://example.com\x1b\\docs\x1b]8;;\x1b\\", link.Render("docs"))
+
+	s := link.With(Underline).Render("see " + Pen{}.Link("https://x.org").Render("x") + " too")
+	assert.Equal(t, "\x1b[4m\x1b]8;;https://example.com\x1b\\see "+
+		"\x1b]8;;https://x.org\x1b\\x\x1b]8;;https://example.com\x1b\\"+
+		" too\x1b]8;;\x1b\\\x1b[0m", s)
+}
+
+func TestPen_Merge(t *testing.T) {
+	base := Pen{}.Foreground(White).Background(Blue).With(Bold)
+	over := Pen{}.Foreground(Yellow).With(Underline).Without(Bold)
+
+	m := base.Merge(over)
+	assert.Equal(t, "\x1b[4;33;44m", m.String())
+	assert.Equal(t, "\x1b[1;37;44m", base.String(), "unchanged")
+
+	assert.Equal(t, m, over.Inherit(base))
+	assert.Equal(t, "\x1b[1;37;44m", Pen{}.Inherit(base).String(), "nothing set, all inherited")
+	assert.Equal(t, "\x1b[1;37;44m", base.Merge(Pen{}).String())
+	assert.Equal(t, "\x1b[1;4;31;44m", Pen{}.Foreground(Red).With(Underline).Inherit(base).String())
+}
+
+func TestWriter_Render(t *testing.T) {
+	out, w := writer()
+	w.SetProfile(Profile16)
+	w.Render(Pen{}.Foreground(T(0xd0, 0x10, 0x10)).With(Bold), "a\tb")
+	assert.Equal(t, "\x1b[1;31ma\tb\x1b[0m", out.String())
+
+	out.Reset()
+	w.SetProfile(ProfileNone)
+	w.Render(Pen{}.Foreground(Red).UnderlineColor(Red), "x")
+	assert.Equal(t, "x", out.String())
+}
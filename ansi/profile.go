@@ -0,0 +1,216 @@
+package ansi
+
+import (
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+)
+
+// How many colors an output can show. Colors written through a Writer are brought down to its Profile
+type Profile int
+
+const (
+	ProfileNone      Profile = iota // no color at all. Styles like Bold still go through
+	Profile16                       // BasicColor only
+	Profile256                      // EBColor and BasicColor
+	ProfileTrueColor                // anything
+)
+
+/*
+Works out what w can show, from the environment:
+  - w not an *os.File, e.g. a bytes.Buffer or a bufio.Writer: ProfileTrueColor, so colors go through as
+    they are. Use SetProfile to bring them down
+  - NO_COLOR set, or w a file that isn't a terminal: ProfileNone
+  - otherwise, what EnvProfile says
+*/
+func DetectProfile(w io.Writer) Profile {
+	f, ok := w.(*os.File)
+	if !ok {
+		return ProfileTrueColor
+	}
+	if !(isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())) {
+		return ProfileNone
+	}
+	return envProfile()
+}
+
+func envProfile() Profile {
+	if os.Getenv("NO_COLOR") != "" {
+		return ProfileNone
+	}
+	return EnvProfile()
+}
+
+/*
+What the terminal can show, going by $TERM, $COLORTERM and the like alone:
+  - TERM=dumb: ProfileNone
+  - COLORTERM=truecolor (or 24bit), a TERM ending in -direct or with truecolor in it, Windows Terminal,
+    or a terminal known to have it (by $TERM_PROGRAM or $TERM), like kitty or iTerm2: ProfileTrueColor
+  - a TERM with 256color in it: Profile256
+  - anything else: Profile16
+
+NO_COLOR is left to DetectProfile: it's what the user wants, not what the terminal can do
+*/
+func EnvProfile() Profile {
+	term := os.Getenv("TERM")
+	switch {
+	case term == "dumb":
+		return ProfileNone
+	case os.Getenv("COLORTERM") == "truecolor" || os.Getenv("COLORTERM") == "24bit",
+		strings.HasSuffix(term, "-direct"), strings.Contains(term, "truecolor"), os.Getenv("WT_SESSION") != "",
+		trueColorTerms[os.Getenv("TERM_PROGRAM")], trueColorTerms[term]:
+		return ProfileTrueColor
+	case strings.Contains(term, "256color"):
+		return Profile256
+	}
+	return Profile16
+}
+
+// $TERM_PROGRAM and $TERM of terminals with truecolor, that don't all set COLORTERM
+var trueColorTerms = map[string]bool{
+	"iTerm.app": true, "WezTerm": true, "vscode": true, "ghostty": true,
+	"xterm-kitty": true, "xterm-ghostty": true, "alacritty": true, "foot": true, "foot-extra": true,
+	"wezterm": true, "contour": true, "rio": true,
+}
+
+/*
+Brings c down to the nearest color p can show, as the eye sees it. Colors p can already show are left alone.
+With ProfileNone, the color that comes back prints as nothing
+*/
+func (p Profile) Convert(c colorable) colorable {
+	switch c := c.(type) {
+	case BgColor:
+		bg := p.Convert(c.c)
+		if _, ok := bg.(noColor); ok {
+			return bg // not Bg(noColor), which would print as a reset
+		}
+		return Bg(bg)
+	case noColor:
+		return c
+	}
+	if p == ProfileNone {
+		return noColor{}
+	}
+	switch c := c.(type) {
+	case EBColor:
+		if p == Profile16 {
+			if c < 16 {
+				return basic(int(c))
+			}
+			return basic(nearest(c.TrueColor(), 0, 16))
+		}
+	case TrueColor:
+		switch p {
+		case Profile256:
+			return EBColor(nearest(c, 16, 256)) // not 0-15, as themes change those
+		case Profile16:
+			return basic(nearest(c, 0, 16))
+		}
+	}
+	return c
+}
+
+// the colors, of the ones on the 256-color palette between from and to, nearest to c
+func nearest(c TrueColor, from, to int) int {
+	palette := paletteLab()
+	l := lab(c)
+	best, min := from, math.Inf(1)
+	for i := from; i < to; i++ {
+		if d := l.dist(palette[i]); d < min {
+			best, min = i, d
+		}
+	}
+	return best
+}
+
+// palette number 0-15 as a BasicColor
+func basic(n int) BasicColor {
+	if n < 8 {
+		return Black + BasicColor(n)
+	}
+	return BrightBlack + BasicColor(n-8)
+}
+
+var (
+	paletteOnce sync.Once
+	palette     [256]labColor
+)
+
+func paletteLab() *[256]labColor {
+	paletteOnce.Do(func() {
+		for i := range palette {
+			palette[i] = lab(EBColor(i).TrueColor())
+		}
+	})
+	return &palette
+}
+
+// a color in CIE L*a*b*, where distance is close to how different colors look
+type labColor struct{ l, a, b float64 }
+
+func (x labColor) dist(y labColor) float64 {
+	dl, da, db := x.l-y.l, x.a-y.a, x.b-y.b
+	return dl*dl + da*da + db*db
+}
+
+// sRGB to L*a*b*, with a D65 white point
+func lab(c TrueColor) labColor {
+	r8, g8, b8 := c.RGB()
+	r, g, b := linear(r8), linear(g8), linear(b8)
+	x := (0.4124*r + 0.3576*g + 0.1805*b) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*b
+	z := (0.0193*r + 0.1192*g + 0.9505*b) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return labColor{116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)}
+}
+
+// back to sRGB, the nearest color there is
+func (x labColor) rgb() TrueColor {
+	fy := (x.l + 16) / 116
+	fx, fz := fy+x.a/500, fy-x.b/200
+	finv := func(t float64) float64 {
+		if t*t*t > 216.0/24389 {
+			return t * t * t
+		}
+		return (116*t - 16) / (24389.0 / 27)
+	}
+	X, Y, Z := finv(fx)*0.95047, finv(fy), finv(fz)*1.08883
+
+	return T(unlinear(3.2406*X-1.5372*Y-0.4986*Z), unlinear(-0.9689*X+1.8758*Y+0.0415*Z), unlinear(0.0557*X-0.2040*Y+1.0570*Z))
+}
+
+// an sRGB channel to linear light, 0 to 1
+func linear(v uint8) float64 {
+	s := float64(v) / 255
+	if s <= 0.04045 {
+		return s / 12.92
+	}
+	return math.Pow((s+0.055)/1.055, 2.4)
+}
+
+// linear light back to an sRGB channel
+func unlinear(v float64) uint8 {
+	if v <= 0.0031308 {
+		return to8(12.92 * v)
+	}
+	return to8(1.055*math.Pow(v, 1/2.4) - 0.055)
+}
+
+// what colors become with ProfileNone
+type noColor struct{}
+
+func (noColor) String() string   { return "" }
+func (noColor) color() string    { return "" }
+func (noColor) effect() string   { return "" }
+func (noColor) bgEffect() string { return "" }
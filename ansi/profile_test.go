@@ -0,0 +1,134 @@
+package ansi
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	tests := map[string]struct {
+		p    Profile
+		c    colorable
+		want colorable
+	}{
+		"truecolor kept":     {p: ProfileTrueColor, c: T(1, 2, 3), want: T(1, 2, 3)},
+		"to 256, cube":       {p: Profile256, c: T(0xff, 0x87, 0), want: EBColor(208)},
+		"to 256, near cube":  {p: Profile256, c: T(0xfe, 0x88, 0x02), want: EBColor(208)},
+		"to 256, grey":       {p: Profile256, c: T(0x80, 0x80, 0x80), want: EBColor(244)},
+		"to 256, black":      {p: Profile256, c: T(0, 0, 0), want: EBColor(16)},
+		"256 kept":           {p: Profile256, c: EBColor(100), want: EBColor(100)},
+		"basic kept":         {p: Profile16, c: Red, want: Red},
+		"to 16":              {p: Profile16, c: T(0xd0, 0x10, 0x10), want: Red},
+		"to 16, bright":      {p: Profile16, c: T(0xff, 0xff, 0x40), want: BrightYellow},
+		"to 16, white":       {p: Profile16, c: T(0xff, 0xff, 0xff), want: BrightWhite},
+		"256 to 16, low":     {p: Profile16, c: EBColor(4), want: Blue},
+		"256 to 16, high":    {p: Profile16, c: EBColor(12), want: BrightBlue},
+		"256 to 16, cube":    {p: Profile16, c: EBColor(46), want: BrightGreen},
+		"background":         {p: Profile256, c: Bg(T(0xff, 0x87, 0)), want: Bg(EBColor(208))},
+		"background to 16":   {p: Profile16, c: Bg(EBColor(9)), want: Bg(BrightRed)},
+		"none":               {p: ProfileNone, c: Red, want: noColor{}},
+		"none, background":   {p: ProfileNone, c: Bg(T(1, 2, 3)), want: noColor{}},
+		"default kept in 16": {p: Profile16, c: DefaultColor, want: DefaultColor},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.p.Convert(tc.c))
+		})
+	}
+}
+
+func TestDetectProfile_NotATerminal(t *testing.T) {
+	f, err := ioutil.TempFile("", "profile")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	assert.Equal(t, ProfileNone, DetectProfile(f))
+
+	w := NewWriter(f)
+	w.Color(Red)
+	w.Effect(Bold, Bg(Blue))
+	b, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "\x1b[1m", string(b), "colors dropped, styles kept")
+}
+
+func TestDetectProfile_NotAFile(t *testing.T) {
+	assert.Equal(t, ProfileTrueColor, DetectProfile(&bytes.Buffer{}))
+	assert.Equal(t, ProfileTrueColor, DetectProfile(bufio.NewWriter(os.Stdout)))
+}
+
+func TestDetectProfile_Env(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		want Profile
+	}{
+		"NO_COLOR":  {env: map[string]string{"NO_COLOR": "1", "COLORTERM": "truecolor"}, want: ProfileNone},
+		"dumb":      {env: map[string]string{"TERM": "dumb"}, want: ProfileNone},
+		"xterm":     {env: map[string]string{"TERM": "xterm"}, want: Profile16},
+		"256":       {env: map[string]string{"TERM": "screen-256color"}, want: Profile256},
+		"colorterm": {env: map[string]string{"TERM": "xterm-256color", "COLORTERM": "24bit"}, want: ProfileTrueColor},
+		"direct":    {env: map[string]string{"TERM": "xterm-direct"}, want: ProfileTrueColor},
+		"truecolor": {env: map[string]string{"TERM": "rxvt-unicode-truecolor"}, want: ProfileTrueColor},
+		"wt":        {env: map[string]string{"TERM": "xterm-256color", "WT_SESSION": "1"}, want: ProfileTrueColor},
+		"kitty":     {env: map[string]string{"TERM": "xterm-kitty"}, want: ProfileTrueColor},
+		"iterm":     {env: map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, want: ProfileTrueColor},
+		"no TERM":   {want: Profile16},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			env := map[string]string{"NO_COLOR": "", "TERM": "", "COLORTERM": "", "WT_SESSION": "", "TERM_PROGRAM": ""}
+			for k, v := range tc.env {
+				env[k] = v
+			}
+			for k, v := range env {
+				old, had := os.LookupEnv(k)
+				os.Setenv(k, v)
+				if had {
+					defer os.Setenv(k, old)
+				} else {
+					defer os.Unsetenv(k)
+				}
+			}
+			assert.Equal(t, tc.want, envProfile())
+		})
+	}
+}
+
+func TestWriter_Profile(t *testing.T) {
+	out, w := writer()
+	w.SetProfile(Profile256)
+	assert.Equal(t, Profile256, w.Profile())
+	w.Color(T(0xff, 0x87, 0))
+	assert.Equal(t, "\x1b[38;5;208m", out.String())
+
+	out.Reset()
+	w.SetProfile(Profile16)
+	w.Effect(T(0xd0, 0x10, 0x10), Bg(EBColor(15)), Underline)
+	assert.Equal(t, "\x1b[31;107;4m", out.String())
+
+	out.Reset()
+	w.Background(EBColor(4))
+	assert.Equal(t, "\x1b[44m", out.String())
+
+	out.Reset()
+	w.SetProfile(ProfileNone)
+	w.Effect(Red)
+	w.Color(Blue)
+	w.Background(Red)
+	w.Color(Bg(T(1, 2, 3)))
+	assert.Equal(t, "", out.String())
+	w.Style(Bold)
+	w.Background(Red)
+	w.Color(Red)
+	assert.Equal(t, "\x1b[1m", out.String(), "Bold isn't reset")
+
+	out.Reset()
+	w.Effect()
+	assert.Equal(t, "\x1b[m", out.String(), "a reset still goes out")
+}
@@ -0,0 +1,271 @@
+package ansi
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+/*
+Text that may already have escape codes in it: colors from Effect or a Pen, links, and so on. These measure
+and cut text by what shows on screen, in terminal columns, and never cut an escape code in half.
+Wide characters, like CJK, take two columns (see github.com/mattn/go-runewidth)
+*/
+
+// Removes every escape code from s, leaving just the text
+func Strip(s string) string {
+	if strings.IndexByte(s, '\x1b') < 0 {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		_, sz := utf8.DecodeRuneInString(s[i:])
+		b.WriteString(s[i : i+sz])
+		i += sz
+	}
+	return b.String()
+}
+
+// How many columns s takes up on screen, not counting escape codes
+func Width(s string) int { return runewidth.StringWidth(Strip(s)) }
+
+/*
+Cuts s down to width columns, ending with tail (like "…") when it had to be cut. s is returned as is when it
+fits. Escape codes after the cut are kept, so a reset or the end of a link at the end of s still happens
+*/
+func Truncate(s string, width int, tail string) string {
+	if Width(s) <= width {
+		return s
+	}
+	width -= Width(tail)
+	var b, rest strings.Builder
+	w, cut := 0, false
+	for i := 0; i < len(s); {
+		if n := escLen(s[i:]); n > 0 {
+			if cut {
+				rest.WriteString(s[i : i+n])
+			} else {
+				b.WriteString(s[i : i+n])
+			}
+			i += n
+			continue
+		}
+		r, sz := utf8.DecodeRuneInString(s[i:])
+		if !cut && w+runewidth.RuneWidth(r) > width {
+			cut = true
+		}
+		if !cut {
+			b.WriteString(s[i : i+sz])
+			w += runewidth.RuneWidth(r)
+		}
+		i += sz
+	}
+	if width >= 0 {
+		b.WriteString(tail)
+	}
+	b.WriteString(rest.String())
+	return b.String()
+}
+
+// Where Pad puts text in the space it has
+type Align int
+
+const (
+	AlignLeft Align = iota
+	AlignRight
+	AlignCenter
+)
+
+// Fills s out with spaces to width columns, to line up columns of text. s wider than that is left as is (see Truncate)
+func Pad(s string, width int, a Align) string {
+	gap := width - Width(s)
+	if gap <= 0 {
+		return s
+	}
+	switch a {
+	case AlignRight:
+		return strings.Repeat(" ", gap) + s
+	case AlignCenter:
+		return strings.Repeat(" ", gap/2) + s + strings.Repeat(" ", gap-gap/2)
+	}
+	return s + strings.Repeat(" ", gap)
+}
+
+/*
+Word-wraps s to lines of at most width columns, breaking at spaces, and inside words only where a word
+is too long for a line by itself. Newlines already in s are kept.
+
+Styles carry across the breaks: each line ends with a reset, and the next starts with whatever
+colors, styles and link were on, so every line stands on its own (e.g. when drawn at a different column)
+*/
+func Wrap(s string, width int) string {
+	if width < 1 {
+		width = 1
+	}
+	w := wrapper{width: width}
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == '\n':
+			w.flushSpaces(false)
+			w.newline()
+			i++
+		case s[i] == ' ':
+			j := i
+			for j < len(s) && s[j] == ' ' {
+				j++
+			}
+			w.spaces += s[i:j]
+			w.spaceWidth += j - i
+			i = j
+		case escLen(s[i:]) > 0 && w.spaces != "":
+			n := escLen(s[i:]) // keep it in order with the spaces, whether they make it to the line or not
+			w.spaces += s[i : i+n]
+			i += n
+		default:
+			j := i
+			for j < len(s) && s[j] != ' ' && s[j] != '\n' {
+				if n := escLen(s[j:]); n > 0 {
+					j += n
+				} else {
+					_, sz := utf8.DecodeRuneInString(s[j:])
+					j += sz
+				}
+			}
+			w.word(s[i:j])
+			i = j
+		}
+	}
+	w.flushSpaces(false)
+	return w.b.String()
+}
+
+// the state of Wrap, one word at a time
+type wrapper struct {
+	b          strings.Builder
+	width      int
+	col        int
+	spaces     string // between the last word and the next one, with any escape codes among them
+	spaceWidth int
+	sgr        []string // the SGR codes in effect, since the last reset
+	link       string   // the link open, as its OSC 8 code
+}
+
+func (w *wrapper) word(s string) {
+	ww := Width(s)
+	if w.col > 0 && w.col+w.spaceWidth+ww > w.width {
+		w.flushSpaces(false)
+		w.newline()
+	} else {
+		w.flushSpaces(true)
+	}
+	if ww <= w.width-w.col {
+		w.write(s)
+		w.col += ww
+		return
+	}
+	for i := 0; i < len(s); { // too long for any line: break it up
+		if n := escLen(s[i:]); n > 0 {
+			w.write(s[i : i+n])
+			i += n
+			continue
+		}
+		r, sz := utf8.DecodeRuneInString(s[i:])
+		rw := runewidth.RuneWidth(r)
+		if w.col > 0 && w.col+rw > w.width {
+			w.newline()
+		}
+		w.write(s[i : i+sz])
+		w.col += rw
+		i += sz
+	}
+}
+
+// writes the spaces waiting since the last word, or if they're at a line break, just the escape codes among them
+func (w *wrapper) flushSpaces(keep bool) {
+	if keep {
+		w.write(w.spaces)
+		w.col += w.spaceWidth
+	} else {
+		for i := 0; i < len(w.spaces); i++ {
+			if n := escLen(w.spaces[i:]); n > 0 {
+				w.write(w.spaces[i : i+n])
+				i += n - 1
+			}
+		}
+	}
+	w.spaces, w.spaceWidth = "", 0
+}
+
+// ends the line, and sets the styles up again on the next one
+func (w *wrapper) newline() {
+	if w.link != "" {
+		w.b.WriteString(linkEnd)
+	}
+	if len(w.sgr) > 0 {
+		w.b.WriteString(csi + "0m")
+	}
+	w.b.WriteByte('\n')
+	for _, c := range w.sgr {
+		w.b.WriteString(c)
+	}
+	w.b.WriteString(w.link)
+	w.col = 0
+}
+
+// writes s, keeping track of the styles and link it turns on and off
+func (w *wrapper) write(s string) {
+	w.b.WriteString(s)
+	for i := 0; i < len(s); i++ {
+		n := escLen(s[i:])
+		if n == 0 {
+			continue
+		}
+		code := s[i : i+n]
+		switch {
+		case code == csi+"m" || code == csi+"0m":
+			w.sgr = w.sgr[:0]
+		case strings.HasPrefix(code, csi) && code[n-1] == 'm':
+			w.sgr = append(w.sgr, code)
+		case code == linkEnd || code == "\x1b]8;;\a":
+			w.link = ""
+		case strings.HasPrefix(code, "\x1b]8;"):
+			w.link = code
+		}
+		i += n - 1
+	}
+}
+
+/*
+The length of the escape code s starts with, or 0 if it doesn't start with one.
+CSI runs to its final byte; OSC to BEL or ST; DCS, SOS, PM and APC to ST; anything else is ESC and one byte
+*/
+func escLen(s string) int {
+	if len(s) < 2 || s[0] != '\x1b' {
+		return 0
+	}
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']', 'P', 'X', '^', '_':
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' && s[1] == ']' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	return 2
+}
@@ -0,0 +1,96 @@
+package ansi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrip(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":                          "plain",
+		"\x1b[31mred\x1b[0m":             "red",
+		"\x1b[38;2;1;2;3;48;5;4mx\x1b[m": "x",
+		"\x1b]8;;https://x.org\x1b\\link\x1b]8;;\x1b\\": "link",
+		"\x1b]0;title\atext":                            "text",
+		"a\x1b[2Kb\x1b7c":                               "abc",
+		"日本\x1b[1m語":                                    "日本語",
+		"cut off \x1b[3":                                "cut off ",
+	} {
+		assert.Equal(t, want, Strip(in), "%q", in)
+	}
+}
+
+func TestWidth(t *testing.T) {
+	assert.Equal(t, 0, Width(""))
+	assert.Equal(t, 3, Width("\x1b[31mred\x1b[0m"))
+	assert.Equal(t, 6, Width("\x1b[1m日本語\x1b[0m"))
+	assert.Equal(t, 4, Width(Pen{}.Link("https://example.com").With(Bold).Render("docs")))
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		tail  string
+		want  string
+	}{
+		{"hello", 10, "…", "hello"},
+		{"hello", 5, "…", "hello"},
+		{"hello world", 8, "…", "hello w…"},
+		{"hello world", 8, "", "hello wo"},
+		{"\x1b[31mhello world\x1b[0m", 6, "…", "\x1b[31mhello…\x1b[0m"},
+		{"\x1b[1mbold\x1b[0m and \x1b[4mmore\x1b[0m", 6, "…", "\x1b[1mbold\x1b[0m …\x1b[4m\x1b[0m"},
+		{"日本語です", 7, "…", "日本語…"},
+		{"日本語です", 6, "…", "日本…"}, // a wide rune doesn't get split
+		{"hello", 0, "…", ""},
+		{"hello", 1, "...", ""},
+	}
+	for _, tc := range tests {
+		got := Truncate(tc.in, tc.width, tc.tail)
+		assert.Equal(t, tc.want, got, "%q to %d", tc.in, tc.width)
+		if tc.want != "" {
+			assert.True(t, Width(got) <= tc.width, "%q fits", got)
+		}
+	}
+}
+
+func TestPad(t *testing.T) {
+	red := Pen{}.Foreground(Red).Render("ab")
+	assert.Equal(t, red+"   ", Pad(red, 5, AlignLeft))
+	assert.Equal(t, "   "+red, Pad(red, 5, AlignRight))
+	assert.Equal(t, " "+red+"  ", Pad(red, 5, AlignCenter))
+	assert.Equal(t, "日本 ", Pad("日本", 5, AlignLeft))
+	assert.Equal(t, "toolong", Pad("toolong", 3, AlignRight))
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{"the quick brown fox", 10, "the quick\nbrown fox"},
+		{"the quick brown fox", 100, "the quick brown fox"},
+		{"a  b", 10, "a  b"},
+		{"one\ntwo three", 5, "one\ntwo\nthree"},
+		{"supercalifragilistic", 8, "supercal\nifragili\nstic"},
+		{"go supercalifragilistic", 8, "go\nsupercal\nifragili\nstic"},
+		{"trailing   ", 20, "trailing"},
+		{"日本語 日本語", 7, "日本語\n日本語"},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.want, Wrap(tc.in, tc.width), "%q to %d", tc.in, tc.width)
+	}
+}
+
+func TestWrap_CarriesStyle(t *testing.T) {
+	s := "plain \x1b[1;31mbold red text\x1b[0m done"
+	assert.Equal(t, "plain \x1b[1;31mbold\x1b[0m\n\x1b[1;31mred text\x1b[0m\ndone", Wrap(s, 10))
+
+	s = "\x1b[31mred \x1b[44mon blue\x1b[0m"
+	assert.Equal(t, "\x1b[31mred \x1b[44mon\x1b[0m\n\x1b[31m\x1b[44mblue\x1b[0m", Wrap(s, 6))
+
+	link := Pen{}.Link("https://x.org").Render("a b")
+	assert.Equal(t, "\x1b]8;;https://x.org\x1b\\a\x1b]8;;\x1b\\\n\x1b]8;;https://x.org\x1b\\b\x1b]8;;\x1b\\", Wrap(link, 1))
+}
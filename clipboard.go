@@ -0,0 +1,30 @@
+package tui
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/pzl/tui/ansi"
+)
+
+/*
+Reads the terminal's clipboard (OSC 52), the counterpart to ansi.Writer.SetClipboard.
+Most terminals don't allow this, or ask the user first, so expect ErrNoReply.
+Works while GetInput is reading, like Query
+*/
+func GetClipboard(s ansi.Selection) (string, error) { return clipboard(askStdin, s) }
+
+// Like the package level GetClipboard, on this Terminal
+func (t *Terminal) GetClipboard(s ansi.Selection) (string, error) { return clipboard(t.ask, s) }
+
+func clipboard(ask func(string, collector) error, s ansi.Selection) (string, error) {
+	reply, err := oscQuery(ask, "52;"+string(s))
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(reply)
+	if err != nil {
+		return "", fmt.Errorf("unexpected reply from the terminal: %q", reply)
+	}
+	return string(data), nil
+}
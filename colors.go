@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pzl/tui/ansi"
+)
+
+/*
+The terminal's default foreground color (OSC 10), as set by the user's theme.
+Returns ErrNoReply if the terminal doesn't say. Works while GetInput is reading, like Query
+*/
+func ForegroundColor() (ansi.TrueColor, error) { return oscColor(askStdin, "10") }
+
+// The terminal's default background color (OSC 11). See ForegroundColor
+func BackgroundColor() (ansi.TrueColor, error) { return oscColor(askStdin, "11") }
+
+// What color n (0-255) of the terminal's palette really is (OSC 4). See ForegroundColor
+func PaletteColor(n int) (ansi.TrueColor, error) { return oscColor(askStdin, "4;"+strconv.Itoa(n)) }
+
+/*
+Whether the terminal has a dark background, to choose colors that can be read on it.
+When the terminal doesn't say, it's assumed dark, unless $COLORFGBG says otherwise
+*/
+func DarkBackground() bool { return darkBackground(BackgroundColor()) }
+
+// Like the package level ForegroundColor, on this Terminal
+func (t *Terminal) ForegroundColor() (ansi.TrueColor, error) { return oscColor(t.ask, "10") }
+
+// Like the package level BackgroundColor, on this Terminal
+func (t *Terminal) BackgroundColor() (ansi.TrueColor, error) { return oscColor(t.ask, "11") }
+
+// Like the package level PaletteColor, on this Terminal
+func (t *Terminal) PaletteColor(n int) (ansi.TrueColor, error) {
+	return oscColor(t.ask, "4;"+strconv.Itoa(n))
+}
+
+// Like the package level DarkBackground, on this Terminal
+func (t *Terminal) DarkBackground() bool { return darkBackground(t.BackgroundColor()) }
+
+// Whether c is a dark color: darker than a mid grey, as the eye sees it
+func IsDark(c ansi.TrueColor) bool {
+	return c.Luminance() < 0.18 // about L* 50
+}
+
+func darkBackground(bg ansi.TrueColor, err error) bool {
+	if err == nil {
+		return IsDark(bg)
+	}
+	// set by rxvt and some others, as "fg;bg" palette numbers. 0-6 and 8 are the dark ones
+	fgbg := os.Getenv("COLORFGBG")
+	if i := strings.LastIndexByte(fgbg, ';'); i >= 0 {
+		if n, err := strconv.Atoi(fgbg[i+1:]); err == nil {
+			return n < 7 || n == 8
+		}
+	}
+	return true
+}
+
+// asks for a color with OSC <code>;?
+func oscColor(ask func(string, collector) error, code string) (ansi.TrueColor, error) {
+	reply, err := oscQuery(ask, code)
+	if err != nil {
+		return 0, err
+	}
+	c, ok := parseXColor(reply)
+	if !ok {
+		return 0, fmt.Errorf("unexpected reply from the terminal: %q", reply)
+	}
+	return c, nil
+}
+
+/*
+parses an X11 color spec, as terminals reply with: rgb:r/g/b, where each is 1 to 4 hex digits.
+s may still have the BEL or ST on the end
+*/
+func parseXColor(s string) (ansi.TrueColor, bool) {
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "\a"), "\x1b\\")
+	switch {
+	case strings.HasPrefix(s, "rgb:"):
+		s = s[4:]
+	case strings.HasPrefix(s, "rgba:"): // rxvt, with alpha last
+		s = s[5:]
+		if i := strings.LastIndexByte(s, '/'); i >= 0 {
+			s = s[:i]
+		}
+	default:
+		return 0, false
+	}
+	parts := strings.Split(s, "/")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	var rgb [3]uint8
+	for i, p := range parts {
+		if len(p) < 1 || len(p) > 4 {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		max := uint64(1)<<(4*uint(len(p))) - 1
+		rgb[i] = uint8((v*255 + max/2) / max) // scaled to 8 bits, rounded
+	}
+	return ansi.T(rgb[0], rgb[1], rgb[2]), true
+}
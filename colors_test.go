@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/pzl/tui/ansi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseXColor(t *testing.T) {
+	for in, want := range map[string]ansi.TrueColor{
+		"rgb:ffff/ffff/ffff":       ansi.T(255, 255, 255),
+		"rgb:0000/0000/0000\x1b\\": ansi.T(0, 0, 0),
+		"rgb:2828/2c2c/3434\a":     ansi.T(0x28, 0x2c, 0x34),
+		"rgb:f/8/0":                ansi.T(255, 0x88, 0),
+		"rgb:ff/80/00":             ansi.T(255, 0x80, 0),
+		"rgb:fff/800/000":          ansi.T(255, 0x80, 0),
+		"rgba:ffff/0000/0000/ffff": ansi.T(255, 0, 0),
+		"rgb:1e1e/1e1e/2e2e\x1b\\": ansi.T(0x1e, 0x1e, 0x2e),
+	} {
+		c, ok := parseXColor(in)
+		assert.True(t, ok, "%q", in)
+		assert.Equal(t, want, c, "%q", in)
+	}
+	for _, in := range []string{"", "rgb:", "rgb:ff/ff", "rgb:fffff/0/0", "rgb:gg/00/00", "#ffffff"} {
+		_, ok := parseXColor(in)
+		assert.False(t, ok, "%q", in)
+	}
+}
+
+func TestIsDark(t *testing.T) {
+	for c, dark := range map[ansi.TrueColor]bool{
+		ansi.T(0, 0, 0):          true,
+		ansi.T(0x28, 0x2c, 0x34): true,  // one dark
+		ansi.T(0x00, 0x2b, 0x36): true,  // solarized dark
+		ansi.T(0xfd, 0xf6, 0xe3): false, // solarized light
+		ansi.T(255, 255, 255):    false,
+		ansi.T(0, 0, 255):        true,
+		ansi.T(255, 255, 0):      false,
+	} {
+		assert.Equal(t, dark, IsDark(c), "%06x", int(c))
+	}
+}
+
+func TestDarkBackground_Env(t *testing.T) {
+	for fgbg, dark := range map[string]bool{"": true, "15;0": true, "0;15": false, "0;default;15": false, "15;8": true, "0;7": false} {
+		restore := setenv(map[string]string{"COLORFGBG": fgbg})
+		assert.Equal(t, dark, darkBackground(0, ErrNoReply), fgbg)
+		restore()
+	}
+}
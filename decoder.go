@@ -0,0 +1,618 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Decoder turns raw terminal input into Events. It does no I/O of its own, so it can decode
+input from anywhere: a terminal, an SSH channel, a websocket, a recorded session.
+
+Bytes are handed over with Feed, in whatever chunks they arrive in. A sequence split across
+chunks is held back until the rest of it arrives. Some input is ambiguous until more arrives,
+or until it's clear nothing more is coming: a lone ESC may be the ESC key, or the start of an
+escape sequence. Call Flush once no input has arrived for a short while (e.g. 50ms)
+to decode whatever is still held back.
+
+Replies to queries (see Query) are decoded as Reply events, rather than mistaken for keys.
+
+The zero value is ready to use.
+*/
+type Decoder struct {
+	buf   []byte
+	paste []byte // non-nil while inside a bracketed paste
+	cpr   int    // cursor position reports expected
+}
+
+/*
+Call after sending a cursor position query (CSI 6n). A report for row 1 looks just like F3 with
+modifiers (CSI 1;2R is Shift-F3, or the cursor at column 2), so on its own, it decodes as the key.
+Once expected, the next report is decoded as a Reply
+*/
+func (d *Decoder) ExpectCursorReport() { d.cpr++ }
+
+// adds n to the cursor position reports expected. n < 0 for reports no longer expected, e.g. after a timeout
+func (d *Decoder) expectCursorReports(n int) {
+	if d.cpr += n; d.cpr < 0 {
+		d.cpr = 0
+	}
+}
+
+// Decodes as many complete events as possible from the input so far, in order
+func (d *Decoder) Feed(b []byte) []Event {
+	d.buf = append(d.buf, b...)
+	return d.drain(false)
+}
+
+// Decodes any input held back waiting for more, as if nothing more will follow.
+// An unfinished bracketed paste is not cut short, it keeps collecting until its end marker
+func (d *Decoder) Flush() []Event { return d.drain(true) }
+
+// Whether input is held back, that a Flush would decode
+func (d *Decoder) Pending() bool { return d.paste == nil && len(d.buf) > 0 }
+
+func (d *Decoder) drain(flush bool) []Event {
+	var evs []Event
+	start := d.buf
+	for {
+		ev, ok := d.next(flush)
+		if !ok {
+			break
+		}
+		evs = append(evs, ev)
+	}
+	// move any leftovers to the front, so the buffer gets reused
+	d.buf = append(start[:0], d.buf...)
+	return evs
+}
+
+// decodes the next event off the buffer. ok is false when there's nothing (yet) to emit
+func (d *Decoder) next(flush bool) (ev Event, ok bool) {
+	for len(d.buf) > 0 {
+		if d.paste != nil {
+			// inside a bracketed paste. Everything up to the end marker is pasted text
+			return d.pasteSequence()
+		}
+		if bytes.HasPrefix(d.buf, []byte(pasteStart)) {
+			// the start of a paste has no event of its own
+			d.buf = d.buf[len(pasteStart):]
+			d.paste = []byte{}
+			continue
+		}
+
+		ev, n := decode(d.buf, flush)
+		if n == 0 {
+			return Event{}, false
+		}
+		if d.cpr > 0 && isCursorReport(d.buf[:n]) {
+			ev = reply(d.buf[:n])
+			d.cpr--
+		}
+		d.buf = d.buf[n:]
+		return ev, true
+	}
+	return Event{}, false
+}
+
+const (
+	pasteStart = "\x1b[200~"
+	pasteEnd   = "\x1b[201~"
+)
+
+// collects the contents of a bracketed paste. Returns the Paste event once the end marker has arrived.
+// Until then, the buffer is moved into d.paste, so a paste may span any number of reads
+func (d *Decoder) pasteSequence() (Event, bool) {
+	if i := bytes.Index(d.buf, []byte(pasteEnd)); i >= 0 {
+		text := string(append(d.paste, d.buf[:i]...))
+		d.buf = d.buf[i+len(pasteEnd):]
+		d.paste = nil
+		return Event{Type: Paste, Text: text}, true
+	}
+
+	// hold back a partial end marker, the rest of it may be in the next read
+	keep := 0
+	for k := len(pasteEnd) - 1; k > 0; k-- {
+		if bytes.HasSuffix(d.buf, []byte(pasteEnd[:k])) {
+			keep = k
+			break
+		}
+	}
+	d.paste = append(d.paste, d.buf[:len(d.buf)-keep]...)
+	d.buf = d.buf[len(d.buf)-keep:]
+	return Event{}, false
+}
+
+// decodes one event from the start of b, returning the number of bytes used.
+// That's 0 when b holds only the start of a sequence, and more input is needed. Never 0 when flushing
+func decode(b []byte, flush bool) (Event, int) {
+	switch b[0] {
+	case 127:
+		return Event{Type: KeySpecial, Key: BSpace}, 1
+	case 0:
+		return Event{Type: KeySpecial, Key: Null, Mod: ModCtrl}, 1 // Ctrl-space?
+	case byte(ESC):
+		return escSequence(b, flush)
+	case byte(Tab), byte(CtrlM):
+		// identical to Ctrl-I and Ctrl-M. Assume the key itself was pressed
+		return Event{Type: KeySpecial, Key: rune(b[0])}, 1
+	}
+
+	if b[0] < 32 { // Ctrl-A_Z
+		return Event{Type: KeySpecial, Key: rune(b[0]), Mod: ModCtrl}, 1
+	}
+	if !utf8.FullRune(b) && !flush {
+		return Event{}, 0
+	}
+	char, sz := utf8.DecodeRune(b)
+	if char == utf8.RuneError {
+		return debugEv(b[:sz]), sz
+	}
+	return Event{Type: KeyPrint, Key: char}, sz
+}
+
+// http://www.manmrk.net/tutorials/ISPF/XE/xehelp/html/HID00000594.htm
+// this is the ugliest, code ever. to check the seemingly most random
+// assignment of codes to meaningful keys
+func escSequence(b []byte, flush bool) (Event, int) {
+	if len(b) < 2 {
+		if !flush {
+			return Event{}, 0
+		}
+		return Event{Type: KeySpecial, Key: ESC}, 1
+	}
+
+	switch b[1] {
+	case byte(ESC):
+		return Event{Type: KeySpecial, Key: ESC}, 2
+	case 127:
+		return Event{Type: KeySpecial, Key: AltBS, Mod: ModAlt}, 2
+	case ']', 'P', '_': // OSC, DCS, APC
+		return stringSequence(b, flush)
+	case 91, 79: // [, O
+		alt := Event{Type: KeySpecial, Key: AltO, Mod: ModAlt}
+		if b[1] == '[' {
+			alt.Key = AltOpenBracket
+		}
+		if len(b) < 3 {
+			if !flush {
+				return Event{}, 0
+			}
+			return alt, 2
+		}
+		if b[1] == '[' && b[2] == 'M' {
+			return mouseSequence(b, flush)
+		}
+
+		params, final, end := csiParams(b, 2)
+		if final == 0 {
+			if end == len(b) && !flush {
+				return Event{}, 0
+			}
+			return debugEv(b[:end]), end
+		}
+		b = b[:end]
+
+		if b[1] == '[' {
+			switch {
+			case b[2] == '<':
+				return sgrMouseSequence(b, params, final), end
+			case b[2] == 'I': // focus reporting: \x1b[I, \x1b[O
+				return Event{Type: FocusIn}, end
+			case b[2] == 'O':
+				return Event{Type: FocusOut}, end
+			case final == '~' && params[0][0] == 201:
+				// stray paste end marker, without a start
+				return Event{Type: KeySpecial, Key: Null}, end
+			case b[2] >= 0x3d && b[2] <= 0x3f, final == 'y' && b[end-2] == '$':
+				// private sequences (DA, kitty keyboard flags...) and DECRQM are replies to queries. Not keys
+				return reply(b), end
+			case final == 'R' && len(params) == 2 && params[0][0] != 1:
+				// a cursor position report. Row 1 is ambiguous, see ExpectCursorReport
+				return reply(b), end
+			}
+		}
+		ev := functionKey(b, params, final)
+		if ev.Type == EventInvalid && (b[1] == 'O' || end == 3) {
+			// not a key after all, but Alt-O or Alt-[ and then whatever was typed next, e.g. \x1bOx
+			return alt, 2
+		}
+		return ev, end
+	}
+
+	// ESC-0 ~ ESC-26 == ctrl-alt-[key]
+	if b[1] >= 1 && b[1] <= 'z'-'a'+1 {
+		return Event{Type: KeySpecial, Key: rune(int(CtrlAlta) + int(b[1]) - 1), Mod: ModCtrl | ModAlt}, 2
+	}
+
+	// ESC-32 ~ ESC-126 == alt-[key]
+	if Printable(int(b[1])) {
+		return Event{Type: KeySpecial, Key: rune(b[1]), Mod: ModAlt}, 2
+	}
+
+	return debugEv(b[:2]), 2
+}
+
+/*
+Function keys, arrows, and the like. Either
+
+	\x1b[<code>;<mods>~   e.g. \x1b[5;3~ is Alt-PgUp
+	\x1b[1;<mods><letter> e.g. \x1b[1;6H is Ctrl-Shift-Home
+	\x1bO<letter>         (SS3)
+
+where the optional mods is 1 + a Modifier bitmask, and may carry a kitty key action as :<action>
+https://invisible-island.net/xterm/ctlseqs/ctlseqs.html#h2-PC-Style-Function-Keys
+*/
+func functionKey(b []byte, params [][]int, final byte) Event {
+	if final == 'u' && b[1] == '[' {
+		return kittyKey(params)
+	}
+	if final == '~' && params[0][0] == 27 && len(params) == 3 {
+		return otherKey(params)
+	}
+
+	mods := []int{0}
+	if len(params) > 1 {
+		mods = params[1]
+	} else if b[1] == 'O' && params[0][0] > 0 {
+		mods = params[0] // some terminals send modified SS3 keys as \x1bO<mods><letter>
+	}
+	mod, action := Modifier(0), KeyPress
+	if mods[0] > 0 {
+		mod = Modifier(mods[0] - 1)
+	}
+	if len(mods) > 1 && mods[1] > 0 {
+		action = KeyAction(mods[1] - 1)
+	}
+
+	var k rune
+	var ok bool
+	if final == '~' && b[1] == '[' {
+		k, ok = tildeKeys[params[0][0]]
+	} else {
+		k, ok = letterKeys[final]
+	}
+	if !ok {
+		return debugEv(b)
+	}
+
+	ev := modKey(k, mod)
+	ev.Action = action
+	return ev
+}
+
+// \x1b[<code>~
+var tildeKeys = map[int]rune{
+	1: Home, 2: Insert, 3: Del, 4: End, 5: PgUp, 6: PgDn, 7: Home, 8: End,
+	11: F1, 12: F2, 13: F3, 14: F4, 15: F5,
+	17: F6, 18: F7, 19: F8, 20: F9, 21: F10,
+	23: F11, 24: F12,
+}
+
+// \x1b[<letter> and \x1bO<letter>
+var letterKeys = map[byte]rune{
+	'A': Up, 'B': Down, 'C': Right, 'D': Left,
+	'H': Home, 'F': End,
+	'P': F1, 'Q': F2, 'R': F3, 'S': F4,
+	'Z': BTab,
+}
+
+// applies modifiers to a function key. The Shift/Ctrl arrow, Shift-Tab and Alt-Del constants
+// are used for exactly those combinations. Anything else is the plain key, with Mod set
+func modKey(k rune, mod Modifier) Event {
+	m := mod &^ (ModCapsLock | ModNumLock)
+	switch {
+	case k >= Up && k <= Left && m == ModShift:
+		k = SUp + k - Up
+	case k >= Up && k <= Left && m == ModCtrl:
+		k = CtrlUp + k - Up
+	case k == Del && m == ModAlt:
+		k = AltDel
+	case k == BTab:
+		mod |= ModShift
+	}
+	return Event{Type: KeySpecial, Key: k, Mod: mod}
+}
+
+// kitty keyboard protocol
+// https://sw.kovidgoyal.net/kitty/keyboard-protocol/
+// \x1b[<code>[:<shifted>[:<base>]] ; <mods>[:<action>] ; <text> u
+func kittyKey(params [][]int) Event {
+	code, shifted, mod, action := rune(params[0][0]), rune(0), Modifier(0), KeyPress
+	if len(params[0]) > 1 {
+		shifted = rune(params[0][1])
+	}
+	if len(params) > 1 {
+		if m := params[1][0]; m > 0 {
+			mod = Modifier(m - 1) // sent as 1 + bitmask
+		}
+		if len(params[1]) > 1 && params[1][1] > 0 {
+			action = KeyAction(params[1][1] - 1)
+		}
+	}
+	if len(params) > 2 && params[2][0] > 0 {
+		shifted = rune(params[2][0]) // actual text the key produces
+	}
+
+	ev := codeKey(code, shifted, mod)
+	ev.Action = action
+	return ev
+}
+
+// xterm modifyOtherKeys: \x1b[27;<mods>;<code>~
+// https://invisible-island.net/xterm/modified-keys.html
+func otherKey(params [][]int) Event {
+	mod := Modifier(0)
+	if m := params[1][0]; m > 0 {
+		mod = Modifier(m - 1)
+	}
+	code := rune(params[2][0])
+
+	// the code is the character typed, so shift has already been applied, e.g. Ctrl-Shift-a sends 'A'
+	if mod&ModShift != 0 && unicode.IsUpper(code) {
+		return codeKey(unicode.ToLower(code), code, mod)
+	}
+	return codeKey(code, 0, mod)
+}
+
+// the kitty protocol's private use area codes for keys without a unicode value.
+// Anything unmapped is passed along as its raw code, as a KeySpecial
+var kittyFunctional = map[rune]rune{
+	57399: '0', 57400: '1', 57401: '2', 57402: '3', 57403: '4', // keypad
+	57404: '5', 57405: '6', 57406: '7', 57407: '8', 57408: '9',
+	57409: '.', 57410: '/', 57411: '*', 57412: '-', 57413: '+',
+	57414: Enter, 57415: '=', 57416: ',',
+	57417: Left, 57418: Right, 57419: Up, 57420: Down,
+	57421: PgUp, 57422: PgDn, 57423: Home, 57424: End, 57425: Insert, 57426: Del,
+}
+
+/*
+Turns a unicode key code plus modifiers into an Event.
+
+Where a legacy key constant exists for the combination, it is used as the Key,
+so matching ev.Key == CtrlC or ev.Key == Altx keeps working. Combinations the
+legacy encoding can't tell apart are distinguished by Mod, e.g. Tab vs Ctrl-I
+are both Key=Tab, but only Ctrl-I has ModCtrl set.
+
+Any other combination reports the unmodified key in Key, with the modifiers in Mod.
+shifted is the text the key produces with shift applied, if known.
+*/
+func codeKey(code rune, shifted rune, mod Modifier) Event {
+	if k, ok := kittyFunctional[code]; ok {
+		code = k
+		if !Printable(int(k)) {
+			return Event{Type: KeySpecial, Key: k, Mod: mod}
+		}
+	}
+	if code >= 0xe000 && code <= 0xf8ff { // the rest of the private use area: F13-F35, media keys, Shift on its own, ...
+		return Event{Type: KeySpecial, Key: code, Mod: mod}
+	}
+
+	m := mod &^ (ModCapsLock | ModNumLock) // lock keys don't change the meaning of a key
+
+	switch code {
+	case Tab:
+		if m == ModShift {
+			return Event{Type: KeySpecial, Key: BTab, Mod: mod}
+		}
+		return Event{Type: KeySpecial, Key: Tab, Mod: mod}
+	case 127, CtrlH:
+		if m == ModAlt {
+			return Event{Type: KeySpecial, Key: AltBS, Mod: mod}
+		}
+		return Event{Type: KeySpecial, Key: BSpace, Mod: mod}
+	}
+	if code < 32 { // Enter, ESC, and any other control code
+		return Event{Type: KeySpecial, Key: code, Mod: mod}
+	}
+
+	switch m {
+	case 0, ModShift: // regular typing
+		if shifted != 0 {
+			code = shifted
+		} else if m == ModShift {
+			code = unicode.ToUpper(code)
+		}
+		return Event{Type: KeyPrint, Key: code, Mod: mod}
+	case ModAlt, ModAlt | ModShift: // Alt constants share their values with the printable keys
+		if shifted != 0 {
+			code = shifted
+		} else if m&ModShift != 0 {
+			code = unicode.ToUpper(code)
+		}
+		if Printable(int(code)) {
+			return Event{Type: KeySpecial, Key: code, Mod: mod}
+		}
+	case ModCtrl:
+		if k, ok := ctrlKey(code); ok {
+			return Event{Type: KeySpecial, Key: k, Mod: mod}
+		}
+	case ModCtrl | ModAlt:
+		if code >= 'a' && code <= 'z' {
+			return Event{Type: KeySpecial, Key: CtrlAlta + code - 'a', Mod: mod}
+		}
+	}
+	return Event{Type: KeySpecial, Key: code, Mod: mod}
+}
+
+// the legacy control code for Ctrl + key, if there is one
+func ctrlKey(r rune) (rune, bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r - 'a' + 1, true
+	case r == ' ', r == '@', r == '2':
+		return Null, true
+	case r >= '[' && r <= '_': // [ \ ] ^ _
+		return r - '[' + ESC, true
+	case r == '6':
+		return CtrlCaret, true
+	case r == '-':
+		return CtrlUnderscore, true
+	}
+	return 0, false
+}
+
+// queries
+
+func reply(b []byte) Event { return Event{Type: Reply, Text: string(b)} }
+
+/*
+OSC, DCS and APC sequences carry a string, up to a string terminator (ESC \, or BEL for OSC).
+The terminal only sends them as replies. Otherwise ESC ] ESC P and ESC _ are Alt keys
+*/
+func stringSequence(b []byte, flush bool) (Event, int) {
+	if ok, more := replyStart(b); !ok {
+		if more && !flush {
+			return Event{}, 0
+		}
+		return Event{Type: KeySpecial, Key: rune(b[1]), Mod: ModAlt}, 2
+	}
+	for i := 2; i < len(b); i++ {
+		if b[i] == 7 && b[1] == ']' {
+			return reply(b[:i+1]), i + 1
+		}
+		if b[i] == byte(ESC) && i+1 < len(b) && b[i+1] == '\\' {
+			return reply(b[:i+2]), i + 2
+		}
+	}
+	if !flush {
+		return Event{}, 0
+	}
+	return debugEv(b), len(b) // never terminated
+}
+
+/*
+whether b starts the way replies do: a number after ESC ] (OSC), >| or 1$ or 0$ after ESC P (XTVERSION,
+DECRQSS), or G after ESC _ (kitty graphics). Anything else is an Alt key, and then whatever was typed next.
+more is set when b is too short to tell yet
+*/
+func replyStart(b []byte) (ok, more bool) {
+	if b[1] == ']' {
+		if len(b) < 3 {
+			return false, true
+		}
+		return b[2] >= '0' && b[2] <= '9', false
+	}
+	for _, p := range []string{"\x1bP>|", "\x1bP1$", "\x1bP0$", "\x1b_G"} {
+		n := len(p)
+		if len(b) < n {
+			n = len(b)
+		}
+		if string(b[:n]) == p[:n] {
+			if n == len(p) {
+				return true, false
+			}
+			more = true
+		}
+	}
+	return false, more
+}
+
+// whether b is a whole cursor position report, CSI row;col R
+func isCursorReport(b []byte) bool {
+	_, _, ok := cursorReport(string(b))
+	return ok
+}
+
+func cursorReport(s string) (row, col int, ok bool) {
+	if !strings.HasPrefix(s, "\x1b[") {
+		return 0, 0, false
+	}
+	params, final, end := csiParams([]byte(s), 2)
+	if final != 'R' || end != len(s) || len(params) != 2 || len(params[0]) != 1 || len(params[1]) != 1 {
+		return 0, 0, false
+	}
+	return params[0][0], params[1][0], true
+}
+
+// mouse stuff
+
+func debugEv(buf []byte) Event {
+	b := make([]byte, len(buf))
+	copy(b, buf)
+	return Event{Type: EventInvalid, M: &MouseEvent{0, 0, 0, false, false, false, false, false, b}}
+}
+
+// https://www.xfree86.org/current/ctlseqs.html#Mouse%20Tracking
+// \x1b[M<button><x+33><y+33>
+func mouseSequence(b []byte, flush bool) (Event, int) {
+	if len(b) < 6 {
+		if !flush {
+			return Event{}, 0
+		}
+		return debugEv(b), len(b)
+	}
+	buf := make([]byte, 6)
+	copy(buf, b)
+
+	evCode := int(b[3] - 32)
+
+	bNum := evCode & 0x3 // low two bits, 00=MB1, 01=MB2, 10=MB3, 11=Release
+	if evCode&(1<<6) != 0 {
+		bNum += 4 // scroll buttons set a high bit (+32)
+	}
+	shift := evCode&(1<<2) != 0  // 4
+	meta := evCode&(1<<3) != 0   // 8
+	ctrl := evCode&(1<<4) != 0   // 16
+	motion := evCode&(1<<5) != 0 //32, motion indicator
+
+	x := int(b[4] - 33)
+	y := int(b[5] - 33) // - yoffset if any
+	release := bNum == 3 && !motion
+	return Event{Type: Mouse, M: &MouseEvent{y, x, bNum, shift, meta, ctrl, motion, release, buf}}, 6
+}
+
+// SGR extended mouse mode (1006). Coordinates are decimal, and not limited to 223
+// \x1b[<button;x;yM for press, and ending in m for release
+func sgrMouseSequence(b []byte, params [][]int, final byte) Event {
+	if (final != 'M' && final != 'm') || len(params) != 3 {
+		return debugEv(b)
+	}
+	buf := make([]byte, len(b))
+	copy(buf, b)
+
+	evCode := params[0][0]
+
+	bNum := evCode & 0x3 // same bit layout as the X10 mode, without the +32 offset
+	if evCode&(1<<6) != 0 {
+		bNum += 4
+	}
+	shift := evCode&(1<<2) != 0
+	meta := evCode&(1<<3) != 0
+	ctrl := evCode&(1<<4) != 0
+	motion := evCode&(1<<5) != 0
+	release := final == 'm'
+
+	x := params[1][0] - 1 // 1-based from the terminal
+	y := params[2][0] - 1
+	return Event{Type: Mouse, M: &MouseEvent{y, x, bNum, shift, meta, ctrl, motion, release, buf}}
+}
+
+// reads the numeric parameters of a CSI sequence, starting at b[i] up to the final byte.
+// Returns the ;-separated params, each with its :-separated sub-params (empty ones are 0),
+// the final byte and the length of the whole sequence.
+// A private marker (<=>?) or intermediate bytes ($, space, etc) are skipped over, check b for them.
+// final is 0 when the sequence isn't terminated within b
+func csiParams(b []byte, i int) (params [][]int, final byte, end int) {
+	p := []int{0}
+	for ; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c >= '0' && c <= '9':
+			p[len(p)-1] = p[len(p)-1]*10 + int(c-'0')
+		case c == ':':
+			p = append(p, 0)
+		case c == ';':
+			params = append(params, p)
+			p = []int{0}
+		case c >= 0x3c && c <= 0x3f, c >= 0x20 && c <= 0x2f:
+		case c >= 0x40 && c <= 0x7e:
+			return append(params, p), c, i + 1
+		default:
+			return params, 0, i
+		}
+	}
+	return params, 0, len(b)
+}
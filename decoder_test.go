@@ -0,0 +1,334 @@
+package tui
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeAll(in string) []Event {
+	var d Decoder
+	return append(d.Feed([]byte(in)), d.Flush()...)
+}
+
+func key(k rune, mod Modifier) Event { return Event{Type: KeySpecial, Key: k, Mod: mod} }
+
+func TestDecoder_Keys(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want Event
+	}{
+		"printable":   {in: "a", want: Event{Type: KeyPrint, Key: 'a'}},
+		"unicode":     {in: "é", want: Event{Type: KeyPrint, Key: 'é'}},
+		"ctrl-c":      {in: "\x03", want: key(CtrlC, ModCtrl)},
+		"tab":         {in: "\t", want: key(Tab, 0)},
+		"enter":       {in: "\r", want: key(Enter, 0)},
+		"backspace":   {in: "\x7f", want: key(BSpace, 0)},
+		"esc":         {in: "\x1b", want: key(ESC, 0)},
+		"alt-x":       {in: "\x1bx", want: key(Altx, ModAlt)},
+		"ctrl-alt-a":  {in: "\x1b\x01", want: key(CtrlAlta, ModCtrl|ModAlt)},
+		"alt-bracket": {in: "\x1b[", want: key(AltOpenBracket, ModAlt)},
+		"up":          {in: "\x1b[A", want: key(Up, 0)},
+		"ss3 up":      {in: "\x1bOA", want: key(Up, 0)},
+		"shift-up":    {in: "\x1b[1;2A", want: key(SUp, ModShift)},
+		"ctrl-left":   {in: "\x1b[1;5D", want: key(CtrlLeft, ModCtrl)},
+		"insert":      {in: "\x1b[2~", want: key(Insert, 0)},
+		"del":         {in: "\x1b[3~", want: key(Del, 0)},
+		"f1":          {in: "\x1bOP", want: key(F1, 0)},
+		"f4":          {in: "\x1bOS", want: key(F4, 0)},
+		"f12":         {in: "\x1b[24~", want: key(F12, 0)},
+		"btab":        {in: "\x1b[Z", want: key(BTab, ModShift)},
+		"focus in":    {in: "\x1b[I", want: Event{Type: FocusIn}},
+		"focus out":   {in: "\x1b[O", want: Event{Type: FocusOut}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, []Event{tc.want}, decodeAll(tc.in))
+		})
+	}
+}
+
+func TestDecoder_Modifiers(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want Event
+	}{
+		"alt-up":          {in: "\x1b[1;3A", want: key(Up, ModAlt)},
+		"ctrl-alt-right":  {in: "\x1b[1;7C", want: key(Right, ModCtrl|ModAlt)},
+		"shift-home":      {in: "\x1b[1;2H", want: key(Home, ModShift)},
+		"ctrl-shift-home": {in: "\x1b[1;6H", want: key(Home, ModCtrl|ModShift)},
+		"ctrl-end":        {in: "\x1b[1;5F", want: key(End, ModCtrl)},
+		"tilde home":      {in: "\x1b[1;5~", want: key(Home, ModCtrl)},
+		"alt-pgup":        {in: "\x1b[5;3~", want: key(PgUp, ModAlt)},
+		"ctrl-shift-pgdn": {in: "\x1b[6;6~", want: key(PgDn, ModCtrl|ModShift)},
+		"shift-insert":    {in: "\x1b[2;2~", want: key(Insert, ModShift)},
+		"alt-del":         {in: "\x1b[3;3~", want: key(AltDel, ModAlt)},
+		"ctrl-del":        {in: "\x1b[3;5~", want: key(Del, ModCtrl)},
+		"shift-f1":        {in: "\x1b[1;2P", want: key(F1, ModShift)},
+		"ctrl-f4":         {in: "\x1b[1;5S", want: key(F4, ModCtrl)},
+		"ss3 ctrl-f1":     {in: "\x1bO5P", want: key(F1, ModCtrl)},
+		"shift-f5":        {in: "\x1b[15;2~", want: key(F5, ModShift)},
+		"alt-f12":         {in: "\x1b[24;3~", want: key(F12, ModAlt)},
+		"super-f10":       {in: "\x1b[21;9~", want: key(F10, ModSuper)},
+		"meta-f11":        {in: "\x1b[23;33~", want: key(F11, ModMeta)},
+		"shift-alt-f8":    {in: "\x1b[19;4~", want: key(F8, ModShift|ModAlt)},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, []Event{tc.want}, decodeAll(tc.in))
+		})
+	}
+}
+
+func TestDecoder_Kitty(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want Event
+	}{
+		"ctrl-c":           {in: "\x1b[99;5u", want: key(CtrlC, ModCtrl)},
+		"ctrl-i":           {in: "\x1b[105;5u", want: key(Tab, ModCtrl)},
+		"tab":              {in: "\x1b[9u", want: key(Tab, 0)},
+		"shift-tab":        {in: "\x1b[9;2u", want: key(BTab, ModShift)},
+		"esc":              {in: "\x1b[27u", want: key(ESC, 0)},
+		"alt-enter":        {in: "\x1b[13;3u", want: key(Enter, ModAlt)},
+		"alt-backspace":    {in: "\x1b[127;3u", want: key(AltBS, ModAlt)},
+		"shift-a":          {in: "\x1b[97;2u", want: Event{Type: KeyPrint, Key: 'A', Mod: ModShift}},
+		"shifted key":      {in: "\x1b[49:33;2u", want: Event{Type: KeyPrint, Key: '!', Mod: ModShift}},
+		"text":             {in: "\x1b[97;1;65u", want: Event{Type: KeyPrint, Key: 'A'}},
+		"alt-a":            {in: "\x1b[97;3u", want: key('a', ModAlt)},
+		"ctrl-alt-a":       {in: "\x1b[97;7u", want: key(CtrlAlta, ModCtrl|ModAlt)},
+		"super-a":          {in: "\x1b[97;9u", want: key('a', ModSuper)},
+		"caps lock":        {in: "\x1b[99;69u", want: key(CtrlC, ModCtrl|ModCapsLock)},
+		"release":          {in: "\x1b[97;1:3u", want: Event{Type: KeyPrint, Key: 'a', Action: KeyRelease}},
+		"repeat arrow":     {in: "\x1b[1;1:2A", want: Event{Type: KeySpecial, Key: Up, Action: KeyRepeat}},
+		"keypad 1":         {in: "\x1b[57400u", want: Event{Type: KeyPrint, Key: '1'}},
+		"keypad enter":     {in: "\x1b[57414u", want: key(Enter, 0)},
+		"keypad up":        {in: "\x1b[57419u", want: key(Up, 0)},
+		"ctrl keypad home": {in: "\x1b[57423;5u", want: key(Home, ModCtrl)},
+		"left shift":       {in: "\x1b[57441;2u", want: key(57441, ModShift)},
+		"left ctrl up":     {in: "\x1b[57442;1:3u", want: Event{Type: KeySpecial, Key: 57442, Action: KeyRelease}},
+		"caps lock key":    {in: "\x1b[57358u", want: key(57358, 0)},
+		"f13":              {in: "\x1b[57376u", want: key(57376, 0)},
+		"shift-f35":        {in: "\x1b[57398;2u", want: key(57398, ModShift)},
+		"media play":       {in: "\x1b[57428u", want: key(57428, 0)},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, []Event{tc.want}, decodeAll(tc.in))
+		})
+	}
+}
+
+func TestDecoder_ModifyOtherKeys(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want Event
+	}{
+		"ctrl-c":         {in: "\x1b[27;5;99~", want: key(CtrlC, ModCtrl)},
+		"ctrl-i":         {in: "\x1b[27;5;105~", want: key(Tab, ModCtrl)},
+		"ctrl-shift-a":   {in: "\x1b[27;6;65~", want: key('a', ModCtrl|ModShift)},
+		"ctrl-1":         {in: "\x1b[27;5;49~", want: key('1', ModCtrl)},
+		"ctrl-period":    {in: "\x1b[27;5;46~", want: key('.', ModCtrl)},
+		"ctrl-enter":     {in: "\x1b[27;5;13~", want: key(Enter, ModCtrl)},
+		"shift-enter":    {in: "\x1b[27;2;13~", want: key(Enter, ModShift)},
+		"shift-tab":      {in: "\x1b[27;2;9~", want: key(BTab, ModShift)},
+		"alt-backspace":  {in: "\x1b[27;3;127~", want: key(AltBS, ModAlt)},
+		"ctrl-alt-a":     {in: "\x1b[27;7;97~", want: key(CtrlAlta, ModCtrl|ModAlt)},
+		"shifted symbol": {in: "\x1b[27;2;33~", want: Event{Type: KeyPrint, Key: '!', Mod: ModShift}},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, []Event{tc.want}, decodeAll(tc.in))
+		})
+	}
+}
+
+func TestDecoder_Mouse(t *testing.T) {
+	evs := decodeAll("\x1b[M !!")
+	assert.Len(t, evs, 1)
+	assert.Equal(t, Mouse, evs[0].Type)
+	assert.Equal(t, 0, evs[0].M.X)
+	assert.Equal(t, 0, evs[0].M.Y)
+	assert.Equal(t, 0, evs[0].M.Btn)
+
+	evs = decodeAll("\x1b[<2;300;400M\x1b[<2;300;400m")
+	assert.Len(t, evs, 2)
+	assert.Equal(t, 299, evs[0].M.X)
+	assert.Equal(t, 399, evs[0].M.Y)
+	assert.Equal(t, 2, evs[0].M.Btn)
+	assert.False(t, evs[0].M.Release)
+	assert.Equal(t, 2, evs[1].M.Btn)
+	assert.True(t, evs[1].M.Release)
+}
+
+func TestDecoder_SGRMouse(t *testing.T) {
+	mouse := func(x, y, btn int, shift, meta, ctrl, motion, release bool) MouseEvent {
+		return MouseEvent{Y: y, X: x, Btn: btn, Shift: shift, Meta: meta, Ctrl: ctrl, Motion: motion, Release: release}
+	}
+	tests := map[string]struct {
+		in   string
+		want MouseEvent
+	}{
+		"left press":       {in: "\x1b[<0;1;1M", want: mouse(0, 0, 0, false, false, false, false, false)},
+		"right release":    {in: "\x1b[<2;10;5m", want: mouse(9, 4, 2, false, false, false, false, true)},
+		"middle, past 223": {in: "\x1b[<1;500;300M", want: mouse(499, 299, 1, false, false, false, false, false)},
+		"shift-ctrl click": {in: "\x1b[<20;3;4M", want: mouse(2, 3, 0, true, false, true, false, false)},
+		"alt click":        {in: "\x1b[<8;3;4M", want: mouse(2, 3, 0, false, true, false, false, false)},
+		"drag":             {in: "\x1b[<32;7;8M", want: mouse(6, 7, 0, false, false, false, true, false)},
+		"wheel up":         {in: "\x1b[<64;2;2M", want: mouse(1, 1, 4, false, false, false, false, false)},
+		"wheel down":       {in: "\x1b[<65;2;2M", want: mouse(1, 1, 5, false, false, false, false, false)},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			evs := decodeAll(tc.in)
+			assert.Len(t, evs, 1)
+			assert.Equal(t, Mouse, evs[0].Type)
+			tc.want.buf = []byte(tc.in)
+			assert.Equal(t, &tc.want, evs[0].M)
+		})
+	}
+
+	t.Run("wrong number of params", func(t *testing.T) {
+		evs := decodeAll("\x1b[<0;1M")
+		assert.Len(t, evs, 1)
+		assert.Equal(t, EventInvalid, evs[0].Type)
+	})
+}
+
+func TestDecoder_Paste(t *testing.T) {
+	var d Decoder
+	assert.Equal(t, []Event{{Type: KeyPrint, Key: 'a'}}, d.Feed([]byte("a\x1b[200~hello\r")))
+	assert.Empty(t, d.Flush(), "a paste isn't cut short by a flush")
+	assert.Empty(t, d.Feed([]byte("world\x1b[20")))
+	assert.Equal(t, []Event{
+		{Type: Paste, Text: "hello\rworld"},
+		{Type: KeyPrint, Key: 'b'},
+	}, d.Feed([]byte("1~b")))
+}
+
+func TestDecoder_PasteContents(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want []Event
+	}{
+		"text":           {in: "\x1b[200~hello\x1b[201~", want: []Event{{Type: Paste, Text: "hello"}}},
+		"empty":          {in: "\x1b[200~\x1b[201~", want: []Event{{Type: Paste, Text: ""}}},
+		"lines":          {in: "\x1b[200~a\nb\r\nc\x1b[201~", want: []Event{{Type: Paste, Text: "a\nb\r\nc"}}},
+		"escapes kept":   {in: "\x1b[200~\x1b[A\x03\x1b\x1b[201~", want: []Event{{Type: Paste, Text: "\x1b[A\x03\x1b"}}},
+		"unicode":        {in: "\x1b[200~日本\x1b[201~", want: []Event{{Type: Paste, Text: "日本"}}},
+		"keys around":    {in: "x\x1b[200~y\x1b[201~\x1b[B", want: []Event{{Type: KeyPrint, Key: 'x'}, {Type: Paste, Text: "y"}, key(Down, 0)}},
+		"two pastes":     {in: "\x1b[200~a\x1b[201~\x1b[200~b\x1b[201~", want: []Event{{Type: Paste, Text: "a"}, {Type: Paste, Text: "b"}}},
+		"stray end mark": {in: "\x1b[201~", want: []Event{key(Null, 0)}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, decodeAll(tc.in))
+		})
+	}
+}
+
+func TestDecoder_PendingEsc(t *testing.T) {
+	var d Decoder
+	assert.Empty(t, d.Feed([]byte("\x1b")))
+	assert.True(t, d.Pending())
+	assert.Equal(t, []Event{key(Up, 0)}, d.Feed([]byte("[A")))
+	assert.False(t, d.Pending())
+
+	assert.Empty(t, d.Feed([]byte("\x1b")))
+	assert.Equal(t, []Event{key(ESC, 0)}, d.Flush())
+}
+
+// feeding a byte at a time decodes the same as feeding everything at once
+func TestDecoder_Split(t *testing.T) {
+	in := "ab\x1b[1;5A\x1b[<0;10;20M\x1b[200~pasted\x1b[201~é\x1b[97;5u\x1b[M !!\x1bOP"
+
+	var d Decoder
+	var evs []Event
+	for i := range []byte(in) {
+		evs = append(evs, d.Feed([]byte{in[i]})...)
+	}
+	evs = append(evs, d.Flush()...)
+
+	assert.Equal(t, decodeAll(in), evs)
+	assert.Len(t, evs, 9)
+}
+
+func TestDecoder_Replies(t *testing.T) {
+	for name, in := range map[string]string{
+		"DA1":             "\x1b[?62;22c",
+		"DA2":             "\x1b[>1;10;0c",
+		"DECRQM":          "\x1b[?2026;2$y",
+		"kitty keyboard":  "\x1b[?1u",
+		"cursor position": "\x1b[12;40R",
+		"OSC, BEL":        "\x1b]11;rgb:0000/0000/0000\x07",
+		"OSC, ST":         "\x1b]11;rgb:ffff/ffff/ffff\x1b\\",
+		"XTVERSION":       "\x1bP>|XTerm(370)\x1b\\",
+		"APC":             "\x1b_Gi=31;OK\x1b\\",
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, []Event{{Type: Reply, Text: in}}, decodeAll(in))
+		})
+	}
+
+	t.Run("alt keys", func(t *testing.T) {
+		assert.Equal(t, []Event{key(AltP, ModAlt)}, decodeAll("\x1bP"))
+		assert.Equal(t, []Event{key(AltCloseBracket, ModAlt)}, decodeAll("\x1b]"))
+		assert.Equal(t, []Event{key(AltP, ModAlt), {Type: KeyPrint, Key: 'x'}}, decodeAll("\x1bPx"))
+		assert.Equal(t, []Event{key(AltP, ModAlt), {Type: KeyPrint, Key: '>'}}, decodeAll("\x1bP>"))
+		assert.Equal(t, []Event{key(AltCloseBracket, ModAlt), {Type: KeyPrint, Key: 'a'}, {Type: KeyPrint, Key: 'b'}},
+			decodeAll("\x1b]ab"))
+		assert.Equal(t, []Event{key('_', ModAlt), {Type: KeyPrint, Key: 'x'}}, decodeAll("\x1b_x"))
+		assert.Equal(t, []Event{key(AltP, ModAlt), key(AltP, ModAlt)}, decodeAll("\x1bP\x1bP"))
+
+		var d Decoder
+		assert.Equal(t, []Event{key(AltP, ModAlt), {Type: KeyPrint, Key: 'x'}}, d.Feed([]byte("\x1bPx")),
+			"without waiting for the ESC timeout")
+	})
+
+	t.Run("split", func(t *testing.T) {
+		var d Decoder
+		assert.Empty(t, d.Feed([]byte("\x1bP")), "could be XTVERSION")
+		assert.Empty(t, d.Feed([]byte(">")))
+		assert.Equal(t, []Event{{Type: Reply, Text: "\x1bP>|tmux 3.4\x1b\\"}}, d.Feed([]byte("|tmux 3.4\x1b\\")))
+
+		assert.Empty(t, d.Feed([]byte("\x1b]11;rgb:00")))
+		assert.Empty(t, d.Feed([]byte("00/0000/0000\x1b")))
+		assert.Equal(t, []Event{{Type: Reply, Text: "\x1b]11;rgb:0000/0000/0000\x1b\\"}, {Type: KeyPrint, Key: 'a'}},
+			d.Feed([]byte("\\a")))
+	})
+
+	t.Run("cursor at row 1", func(t *testing.T) {
+		var d Decoder
+		assert.Equal(t, []Event{key(F3, ModShift)}, d.Feed([]byte("\x1b[1;2R")), "looks like Shift-F3")
+		d.ExpectCursorReport()
+		assert.Equal(t, []Event{{Type: Reply, Text: "\x1b[1;2R"}, key(F3, ModShift)}, d.Feed([]byte("\x1b[1;2R\x1b[1;2R")))
+	})
+}
+
+func TestDecoder_AltBracketO(t *testing.T) {
+	tests := map[string]struct {
+		in   string
+		want []Event
+	}{
+		"alt-o x":      {in: "\x1bOx", want: []Event{key(AltO, ModAlt), {Type: KeyPrint, Key: 'x'}}},
+		"alt-o digits": {in: "\x1bO5x", want: []Event{key(AltO, ModAlt), {Type: KeyPrint, Key: '5'}, {Type: KeyPrint, Key: 'x'}}},
+		"alt-[ x":      {in: "\x1b[x", want: []Event{key(AltOpenBracket, ModAlt), {Type: KeyPrint, Key: 'x'}}},
+		"alt-[ tilde":  {in: "\x1b[~", want: []Event{key(AltOpenBracket, ModAlt), {Type: KeyPrint, Key: '~'}}},
+		"alt-o alone":  {in: "\x1bO", want: []Event{key(AltO, ModAlt)}},
+		"still a key":  {in: "\x1bOA", want: []Event{key(Up, 0)}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, decodeAll(tc.in))
+		})
+	}
+}
+
+func TestDecoder_Invalid(t *testing.T) {
+	evs := decodeAll("\x1b[99;99X")
+	assert.Len(t, evs, 1)
+	assert.Equal(t, EventInvalid, evs[0].Type)
+}
@@ -0,0 +1,207 @@
+package tui
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pzl/tui/ansi"
+)
+
+/*
+What the terminal can do, from Detect. Worked out from the environment ($TERM, $COLORTERM, $TERM_PROGRAM)
+and from asking the terminal itself, where it answers
+*/
+type Capabilities struct {
+	Name    string // e.g. "xterm", "kitty", "WezTerm". Empty if unknown
+	Version string
+	Colors  int // 0, 16, 256, or 16777216 for truecolor
+
+	SyncOutput     bool // synchronized output (mode 2026), to draw a frame without tearing
+	KittyKeyboard  bool // see ansi.Writer.KittyKeyboard
+	KittyGraphics  bool
+	Sixel          bool
+	SGRMouse       bool
+	BracketedPaste bool
+	FocusEvents    bool
+	Hyperlinks     bool // OSC 8
+
+	Replied bool // whether the terminal answered at all. If not, all of this is guessed from the environment
+}
+
+// number of colors, for Capabilities.Colors
+const (
+	ColorsNone      = 0 // TERM=dumb
+	Colors16        = 16
+	Colors256       = 256
+	ColorsTrueColor = 1 << 24
+)
+
+/*
+Finds out what the terminal on stdin (or the controlling terminal) can do. The terminal is asked with
+DA1, DA2, XTVERSION and DECRQM queries, all at once, so it takes one round trip. A terminal that doesn't
+answer costs a timeout (one second).
+Like Query, this works while GetInput is reading
+*/
+func Detect() Capabilities { return detect(askStdin) }
+
+// Like the package level Detect, on this Terminal
+func (t *Terminal) Detect() Capabilities { return detect(t.ask) }
+
+// modes asked about with DECRQM
+const (
+	modeSGRMouse       = 1006
+	modeFocus          = 1004
+	modeBracketedPaste = 2004
+	modeSync           = 2026
+)
+
+func detect(ask func(string, collector) error) Capabilities {
+	c := fromEnv()
+
+	modes := map[int]bool{}
+	da2, da2Version := -1, ""
+	var xtversion string
+	probes := "\x1b[>0q" + // XTVERSION
+		"\x1b[>c" + // DA2
+		"\x1b[?u" + // kitty keyboard flags
+		"\x1b_Gi=31,s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\" // a kitty graphics query, for a 1x1 image
+	for _, m := range []int{modeSGRMouse, modeFocus, modeBracketedPaste, modeSync} {
+		probes += "\x1b[?" + strconv.Itoa(m) + "$p"
+	}
+	probes += "\x1b[c" // DA1 last. Everything answers it, so once it's in, there are no more replies coming
+
+	err := ask(probes, func(reply string) (bool, bool) {
+		switch {
+		case strings.HasPrefix(reply, "\x1bP>|"): // XTVERSION
+			xtversion = strings.TrimSuffix(reply[4:], "\x1b\\")
+		case strings.HasPrefix(reply, "\x1b_G"):
+			c.KittyGraphics = true
+		case strings.HasPrefix(reply, "\x1b[?") && strings.HasSuffix(reply, "$y"): // DECRQM
+			p, _, _ := csiParams([]byte(reply), 3)
+			if len(p) == 2 {
+				// 0 is not recognized. 1-4 are set, reset, permanently set, permanently reset
+				modes[p[0][0]] = p[1][0] == 1 || p[1][0] == 2 || p[1][0] == 3
+			}
+		case strings.HasPrefix(reply, "\x1b[?") && strings.HasSuffix(reply, "u"):
+			c.KittyKeyboard = true
+		case strings.HasPrefix(reply, "\x1b[>") && strings.HasSuffix(reply, "c"): // DA2
+			p, _, _ := csiParams([]byte(reply), 3)
+			da2 = p[0][0]
+			if len(p) > 1 {
+				da2Version = strconv.Itoa(p[1][0])
+			}
+		case strings.HasPrefix(reply, "\x1b[?") && strings.HasSuffix(reply, "c"): // DA1
+			p, _, _ := csiParams([]byte(reply), 3)
+			for _, a := range p[1:] {
+				if a[0] == 4 {
+					c.Sixel = true
+				}
+			}
+			return true, true
+		default:
+			return false, false
+		}
+		return true, false
+	})
+	if err != nil {
+		return c
+	}
+	c.Replied = true
+
+	if xtversion != "" {
+		c.Name, c.Version = splitVersion(xtversion)
+	} else if name, ok := da2Names[da2]; ok && c.Name == "" {
+		c.Name, c.Version = name, da2Version
+	}
+
+	if len(modes) > 0 {
+		c.SGRMouse = modes[modeSGRMouse]
+		c.FocusEvents = modes[modeFocus]
+		c.BracketedPaste = modes[modeBracketedPaste]
+		c.SyncOutput = modes[modeSync]
+	}
+	if knownTrueColor(c.Name) && c.Colors < ColorsTrueColor {
+		c.Colors = ColorsTrueColor
+	}
+	if knownHyperlinks(c.Name) {
+		c.Hyperlinks = true
+	}
+	return c
+}
+
+var profileColors = map[ansi.Profile]int{
+	ansi.ProfileNone:      ColorsNone,
+	ansi.Profile16:        Colors16,
+	ansi.Profile256:       Colors256,
+	ansi.ProfileTrueColor: ColorsTrueColor,
+}
+
+// a best guess, from environment variables alone
+func fromEnv() Capabilities {
+	term := os.Getenv("TERM")
+	c := Capabilities{
+		Name:    os.Getenv("TERM_PROGRAM"),
+		Version: os.Getenv("TERM_PROGRAM_VERSION"),
+	}
+	if c.Name == "" {
+		c.Name = termNames[term]
+	}
+
+	c.Colors = profileColors[ansi.EnvProfile()] // the same as an ansi.Writer goes by
+
+	// xterm and the many terminals that copy it have had these for years
+	xtermish := strings.HasPrefix(term, "xterm") || strings.HasPrefix(term, "screen") || strings.HasPrefix(term, "tmux")
+	c.SGRMouse, c.BracketedPaste, c.FocusEvents = xtermish, xtermish, xtermish
+
+	c.Hyperlinks = knownHyperlinks(c.Name) || os.Getenv("VTE_VERSION") != ""
+	return c
+}
+
+// "kitty(0.26.5)" or "WezTerm 20230408" or "tmux 3.3a"
+func splitVersion(s string) (name, version string) {
+	if i := strings.IndexByte(s, '('); i > 0 && strings.HasSuffix(s, ")") {
+		return s[:i], s[i+1 : len(s)-1]
+	}
+	if i := strings.IndexByte(s, ' '); i > 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// terminals with a $TERM of their own
+var termNames = map[string]string{
+	"xterm-kitty":   "kitty",
+	"xterm-ghostty": "ghostty",
+	"foot":          "foot",
+	"foot-extra":    "foot",
+	"alacritty":     "alacritty",
+	"wezterm":       "WezTerm",
+	"contour":       "contour",
+	"rio":           "rio",
+}
+
+// terminals that identify themselves in DA2, without XTVERSION
+var da2Names = map[int]string{
+	41: "xterm",
+	65: "VTE",
+	77: "mintty",
+	83: "screen",
+	84: "tmux",
+}
+
+func knownTrueColor(name string) bool {
+	switch strings.ToLower(name) {
+	case "kitty", "wezterm", "iterm.app", "ghostty", "foot", "alacritty", "vscode", "contour", "rio", "mintty", "vte":
+		return true
+	}
+	return false
+}
+
+func knownHyperlinks(name string) bool {
+	switch strings.ToLower(name) {
+	case "kitty", "wezterm", "iterm.app", "ghostty", "foot", "alacritty", "vscode", "contour", "rio", "vte", "mintty":
+		return true
+	}
+	return false
+}
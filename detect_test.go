@@ -0,0 +1,73 @@
+package tui
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sets environment variables for a test, returning a func that puts them back
+func setenv(vars map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range vars {
+		if o, ok := os.LookupEnv(k); ok {
+			old[k] = &o
+		} else {
+			old[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+func TestDetect_Env(t *testing.T) {
+	tests := map[string]struct {
+		env    map[string]string
+		name   string
+		colors int
+	}{
+		"dumb":      {env: map[string]string{"TERM": "dumb"}, colors: ColorsNone},
+		"xterm":     {env: map[string]string{"TERM": "xterm"}, colors: Colors16},
+		"256":       {env: map[string]string{"TERM": "xterm-256color"}, colors: Colors256},
+		"colorterm": {env: map[string]string{"TERM": "xterm-256color", "COLORTERM": "truecolor"}, colors: ColorsTrueColor},
+		"kitty":     {env: map[string]string{"TERM": "xterm-kitty"}, name: "kitty", colors: ColorsTrueColor},
+		"iterm":     {env: map[string]string{"TERM": "xterm-256color", "TERM_PROGRAM": "iTerm.app"}, name: "iTerm.app", colors: ColorsTrueColor},
+		"truecolor": {env: map[string]string{"TERM": "rxvt-unicode-truecolor"}, colors: ColorsTrueColor},
+		"wt":        {env: map[string]string{"TERM": "xterm-256color", "WT_SESSION": "1"}, colors: ColorsTrueColor},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			env := map[string]string{"COLORTERM": "", "TERM_PROGRAM": "", "TERM_PROGRAM_VERSION": "", "VTE_VERSION": "", "WT_SESSION": ""}
+			for k, v := range tc.env {
+				env[k] = v
+			}
+			defer setenv(env)()
+
+			c := fromEnv()
+			assert.Equal(t, tc.name, c.Name)
+			assert.Equal(t, tc.colors, c.Colors)
+			assert.False(t, c.Replied)
+		})
+	}
+}
+
+func TestSplitVersion(t *testing.T) {
+	for in, want := range map[string][2]string{
+		"kitty(0.26.5)":    {"kitty", "0.26.5"},
+		"XTerm(370)":       {"XTerm", "370"},
+		"WezTerm 20230408": {"WezTerm", "20230408"},
+		"foot":             {"foot", ""},
+	} {
+		name, version := splitVersion(in)
+		assert.Equal(t, want, [2]string{name, version}, in)
+	}
+}
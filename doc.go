@@ -1,6 +1,12 @@
 /*
 tui is a very thin wrapper around terminal events. The tui package itself provides for mostly just keyboard + mouse input from the terminal. It handles input.
 
+For full-screen programs, Open starts a Terminal session: raw input, plus the alternate screen, mouse reporting and so on if asked for. Closing it puts everything back the way it was, even when the program is interrupted.
+
+Queries to the terminal, like CursorPosition, Detect or BackgroundColor, have their replies picked out of the input, so they work while events are being read.
+
+The input decoding itself is available on its own as a Decoder, for input that doesn't come from a local terminal.
+
 Output is handled mostly through the ansi subpackage here. It handles:
   - text formatting like color, underline
   - cursor movement
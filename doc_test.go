@@ -29,3 +29,31 @@ func Example() {
 		}
 	}
 }
+
+func ExampleDecoder() {
+	var d tui.Decoder
+	names := map[rune]string{tui.CtrlUp: "CtrlUp", tui.ESC: "ESC"}
+	print := func(evs []tui.Event) {
+		for _, ev := range evs {
+			switch ev.Type {
+			case tui.KeyPrint:
+				fmt.Println("typed", string(ev.Key))
+			case tui.KeySpecial:
+				fmt.Printf("key %s mod=%v\n", names[ev.Key], ev.Mod)
+			}
+		}
+	}
+
+	// input can arrive in any size chunks, e.g. from a network connection
+	print(d.Feed([]byte("hi\x1b[1;5")))
+	print(d.Feed([]byte("A\x1b")))
+
+	// nothing more arrived in a while. That last ESC was a keypress on its own
+	print(d.Flush())
+
+	// Output:
+	// typed h
+	// typed i
+	// key CtrlUp mod=Ctrl
+	// key ESC mod=
+}
@@ -2,10 +2,13 @@ package tui
 
 import (
 	"context"
-	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
 	"sync"
 	"time"
-	"unicode/utf8"
 
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -223,8 +226,14 @@ const (
 	CtrlAltx
 	CtrlAlty
 	CtrlAltz
+
+	Insert // added last, so the values above stay as they were
 )
 
+// Enter is reported as Ctrl-M by terminals. With the kitty keyboard protocol,
+// the two can be told apart: Ctrl-M has ModCtrl set in Event.Mod
+const Enter = CtrlM
+
 type EvType uint8
 
 const (
@@ -232,32 +241,90 @@ const (
 	KeySpecial
 	KeyPrint
 	Mouse
+	Paste  // bracketed paste. The pasted text is in Event.Text
+	Resize // terminal size changed. The new size is in Event.Width and Event.Height
+	FocusIn
+	FocusOut
+	Resume // the terminal was handed back after a Suspend. Redraw everything. The size is in Event.Width and Event.Height
+	Reply  // a reply to a query nobody was waiting for (see Query). The whole sequence is in Event.Text
+)
+
+/*
+Modifier keys held along with a key, as a bitmask. Filled in for every key where the terminal reports them.
+
+For compatibility, combinations that have their own key constant still use it, e.g. Shift-Up is
+Key=SUp with Mod=ModShift. Other combinations report the plain key: Ctrl-Shift-PgDn is Key=PgDn
+with Mod=ModCtrl|ModShift
+*/
+type Modifier uint8
+
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+	ModSuper // xterm calls this one Meta
+	ModHyper
+	ModMeta
+	ModCapsLock
+	ModNumLock
+)
+
+func (m Modifier) String() string {
+	names := []string{"Shift", "Alt", "Ctrl", "Super", "Hyper", "Meta", "CapsLock", "NumLock"}
+	s := []string{}
+	for i, n := range names {
+		if m&(1<<uint(i)) != 0 {
+			s = append(s, n)
+		}
+	}
+	return strings.Join(s, "+")
+}
+
+// Whether a key was pressed, held down, or let go.
+// Repeats and releases are only reported with the kitty keyboard protocol (see ansi.Writer.KittyKeyboard)
+type KeyAction uint8
+
+const (
+	KeyPress KeyAction = iota
+	KeyRepeat
+	KeyRelease
 )
 
 /*
 How to determine mouse action:
-	Mousedown: Type=Mouse && Btn != 3 && !Motion
-	Mouseup:   Type=Mouse && Btn == 3 && !Motion
+	Mousedown: Type=Mouse && !Release && Btn != 3 && !Motion
+	Mouseup:   Type=Mouse && Release
 	Mousedrag: Type=Mouse && Btn != 3 && Motion
 	Mousemove: Type=Mouse && Btn == 3 && Motion
 	ScrollUp:  Type=Mouse && Btn=4
 	ScrollDn:  Type=Mouse && Btn=5
+
+In the legacy (X10) mouse mode, a release does not say which button was let go,
+and is reported with Btn=3. With SGR mode enabled (ansi.Writer.MouseSGR), Btn
+holds the released button.
 */
 type MouseEvent struct {
-	Y      int
-	X      int
-	Btn    int // 0=Primary, 1=Middle, 2=Right, 3=Release, 4=ScrUp, 5=ScrDown
-	Shift  bool
-	Meta   bool
-	Ctrl   bool
-	Motion bool
-	buf    []byte
+	Y       int
+	X       int
+	Btn     int // 0=Primary, 1=Middle, 2=Right, 3=Release, 4=ScrUp, 5=ScrDown
+	Shift   bool
+	Meta    bool
+	Ctrl    bool
+	Motion  bool
+	Release bool
+	buf     []byte
 }
 
 type Event struct {
-	Type EvType
-	Key  rune
-	M    *MouseEvent
+	Type   EvType
+	Key    rune
+	Mod    Modifier  // modifier keys held, when the terminal reports them
+	Action KeyAction // press, repeat or release
+	M      *MouseEvent
+	Text   string // pasted text, for Paste events. The reply, for Reply events
+
+	Width  int // new terminal size, for Resize events
+	Height int
 }
 
 // returns true if a specific character int(rune) is a printable character (alphanumeric, punctuation)
@@ -269,322 +336,333 @@ Grabs Stdin(or whatever passed fd) to listen for keyboard input. Returns 3 thing
  - a terminal restore function. Always safe to call, especially when error is set
  - error condition
 
-This is the primary use of the top-level tui package, if you intend to capture input, or mouse events
+This is the primary use of the top-level tui package, if you intend to capture input, or mouse events.
+Changes to the terminal size arrive on the same channel, as Resize events.
+
+If fd isn't a terminal, e.g. stdin is a pipe, input is read from the controlling terminal instead (see OpenTTY)
+
+Input stops when ctx is done, or when restore is called, and the channel is then closed. restore
+waits for the reading goroutine to exit before putting the terminal back, so nothing is left reading fd
 */
-func GetInput(ctx context.Context, fd int) (<-chan Event, func() error, error) {
-	ch := make(chan Event, 1000)
+func GetInput(ctx context.Context, fd int, opts ...Option) (<-chan Event, func() error, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	o := newOptions(opts)
+	done := func() error { return nil }
+	var tty *os.File
+	if !IsTTY(uintptr(fd)) {
+		if f, err := OpenTTY(); err == nil {
+			tty, fd, done = f, int(f.Fd()), f.Close
+			o.ctty = true
+		}
+	}
+
 	st, err := terminal.GetState(fd)
 	if err != nil {
-		return nil, func() error { return nil }, err
+		return nil, done, err
+	}
+	restore := func() error {
+		err := terminal.Restore(fd, st)
+		done()
+		return err
 	}
-	restore := func() error { return terminal.Restore(fd, st) }
 
-	_, err = terminal.MakeRaw(fd)
+	err = setMode(fd, o)
 	if err != nil {
 		return nil, restore, err
 	}
 
-	ib := inputBuf{b: make([]byte, 0, 9)}
+	in, err := startInput(ctx, fd, o)
+	if err != nil {
+		return nil, restore, err
+	}
+	sessions.add(&session{
+		in:    in,
+		tty:   tty,
+		reset: func() error { return terminal.Restore(fd, st) },
+		apply: func() error { return setMode(fd, o) },
+	})
+	return in.ch, func() error {
+		in.stop()
+		return restore()
+	}, nil
+}
+
+/*
+Puts the terminal GetInput is reading back the way it was found, and stops the program (and the rest of its
+process group), like Ctrl-Z would have in a shell. When the program is continued (fg or bg), input is set up
+again and a Resume event is sent, so the screen can be redrawn.
+
+Screen modes the program set itself, like the alternate screen or mouse reporting, are for it to undo before
+Suspend, and to set again on Resume. A Terminal does all of that by itself, see Terminal.Suspend.
+
+Suspend returns without waiting for the program to be continued.
+Not supported on windows
+*/
+func Suspend() error {
+	ss := sessions.running()
+	for _, s := range ss {
+		s.in.pause()
+		s.reset()
+	}
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, sigCont)
+	if err := stopProcess(); err != nil {
+		signal.Stop(cont)
+		resumeSessions(ss)
+		return err
+	}
 	go func() {
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case ev := <-ib.readEvent(fd):
-				ch <- ev
-			}
-		}
+		<-cont
+		signal.Stop(cont)
+		resumeSessions(ss)
 	}()
-	return ch, restore, nil
+	return nil
 }
 
-type inputBuf struct {
-	b  []byte
-	mu sync.Mutex
-}
+/*
+Runs cmd with the terminal handed over to it, e.g. to open $EDITOR on a file. GetInput stops reading
+while cmd runs, and the terminal is put back the way GetInput found it. Afterwards input is set up
+again, and a Resume event is sent so the screen can be redrawn.
 
-func (ib *inputBuf) readEvent(fd int) <-chan Event {
-	ch := make(chan Event)
+Screen modes the program set itself, like the alternate screen or mouse reporting, are for it to undo before
+Exec, and to set again on Resume. A Terminal does all of that by itself, see Terminal.Exec.
 
-	go func() {
-		ib.mu.Lock()
-		defer func() {
-			ib.mu.Unlock()
-		}()
+cmd's Stdin defaults to os.Stdin, or to the controlling terminal where GetInput is reading that in its place.
+Stdout and Stderr default to os.Stdout and os.Stderr. Like system(3), Ctrl-C is left to cmd.
+On windows, where reads can't be interrupted, input is still read while cmd runs
+*/
+func Exec(cmd *exec.Cmd) error {
+	ss := sessions.running()
+	for _, s := range ss {
+		s.in.pause()
+		s.reset()
+	}
 
-		for ; len(ib.b) == 0; ib.b = fillBuf(fd, ib.b) {
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+		for _, s := range ss {
+			if s.tty != nil {
+				cmd.Stdin = s.tty
+				break
+			}
 		}
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	intr := make(chan os.Signal, 1)
+	signal.Notify(intr, os.Interrupt) // rather than being killed by it
+	err := cmd.Run()
+	signal.Stop(intr)
 
-		if len(ib.b) == 0 {
-			close(ch)
-			return
+	if e := resumeSessions(ss); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+// sets input up again after Suspend or Exec, and sends Resume events
+func resumeSessions(ss []*session) error {
+	var err error
+	for _, s := range ss {
+		if s.in.ctx.Err() != nil { // restored and stopped in the meantime
+			continue
 		}
+		if e := s.apply(); e != nil && err == nil {
+			err = e
+		}
+		s.in.resume()
+		w, h := TermSize(s.in.r.fd)
+		s.in.send(Event{Type: Resume, Width: w, Height: h})
+	}
+	return err
+}
 
-		sz := 1
-		defer func() {
-			ib.b = ib.b[sz:]
-		}()
+// input started by GetInput, which Suspend and Exec hand back to the terminal
+type session struct {
+	in           *input
+	tty          *os.File     // the controlling terminal, when opened in place of GetInput's fd
+	reset, apply func() error // puts the terminal back the way GetInput found it, and sets it up again
+}
 
-		switch ib.b[0] {
-		case byte(CtrlC), byte(CtrlG), byte(CtrlQ):
-			ch <- Event{KeySpecial, rune(ib.b[0]), nil}
-			return
-		case 127:
-			ch <- Event{KeySpecial, BSpace, nil}
-			return
-		case 0:
-			ch <- Event{KeySpecial, Null, nil} // Ctrl-space?
-			return
-		case byte(ESC):
-			ch <- ib.escSequence(&sz)
-			return
-		}
+// the GetInput calls with input still running
+var sessions = &sessionList{}
 
-		if ib.b[0] < 32 { // Ctrl-A_Z
-			ch <- Event{KeySpecial, rune(ib.b[0]), nil}
-			return
-		}
-		char, rsz := utf8.DecodeRune(ib.b)
-		if char == utf8.RuneError {
-			ch <- Event{KeySpecial, ESC, nil}
-			return
+type sessionList struct {
+	mu sync.Mutex
+	ss []*session
+}
+
+// adds s, until its input stops
+func (l *sessionList) add(s *session) {
+	l.mu.Lock()
+	l.ss = append(l.ss, s)
+	l.mu.Unlock()
+	go func() {
+		<-s.in.ctx.Done()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		for i, x := range l.ss {
+			if x == s {
+				l.ss = append(l.ss[:i], l.ss[i+1:]...)
+				return
+			}
 		}
-		sz = rsz
-		ch <- Event{KeyPrint, char, nil}
 	}()
-	return ch
 }
 
-/*
- * Gets first byte, blocking to do so.
- * Tries to get any extra bytes within a 100ms timespan
- * like esc key sequences (arrows, etc)
- *
- */
-func fillBuf(fd int, buf []byte) []byte {
-	const pollInt = 5 //ms
-	const span = 100  //ms -- reflected via retries*pollInt
-
-	c, ok := getchar(fd, false)
-	if !ok {
-		return buf
-	}
-	buf = append(buf, byte(c))
-	retries := 0
-	if c == int(ESC) {
-		retries = span / pollInt // 20
-	}
+func (l *sessionList) running() []*session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]*session(nil), l.ss...)
+}
+
+// sends a Resize event each time the terminal changes size
+func watchResize(ctx context.Context, fd int, ch chan<- Event) {
+	winch := make(chan os.Signal, 1)
+	notifyResize(winch)
+	defer signal.Stop(winch)
 
-	pc := c
 	for {
-		c, ok := getchar(fd, true)
-		if !ok {
-			if retries > 0 {
-				retries--
-				time.Sleep(pollInt * time.Millisecond)
-				continue
+		select {
+		case <-ctx.Done():
+			return
+		case <-winch:
+			w, h := TermSize(fd)
+			select {
+			case ch <- Event{Type: Resize, Width: w, Height: h}:
+			case <-ctx.Done():
+				return
 			}
-			break
-		} else if c == int(ESC) && pc != c {
-			retries = span / pollInt // got the next char, keep going
-		} else {
-			retries = 0
 		}
-		buf = append(buf, byte(c))
-		pc = c
 	}
-	return buf
 }
 
-func getchar(fd int, nonblock bool) (int, bool) {
-	b := make([]byte, 1)
-	err := setNonBlock(fd, nonblock)
-	if err != nil {
-		return 0, false
-	}
-	if n, err := sysRead(fd, b); err != nil || n < 1 {
-		return 0, false
-	}
-	return int(b[0]), true
+// An Option changes how input is read, for GetInput and Events
+type Option func(*options)
+
+type options struct {
+	escTimeout time.Duration
+	mode       Mode
+	clear, set TermFlags
+	ctty       bool // reading the controlling terminal, in place of the fd asked for
 }
 
-//@todo: more shift/ctrl/alt of extended keys like Home, F#, PgUp
-//http://www.manmrk.net/tutorials/ISPF/XE/xehelp/html/HID00000594.htm
-//this is the ugliest, code ever. to check the seemingly most random
-//assignment of codes to meaningful keys
-func (ib *inputBuf) escSequence(sz *int) Event {
-	if len(ib.b) < 2 {
-		return Event{KeySpecial, ESC, nil}
+func newOptions(opts []Option) options {
+	o := options{escTimeout: 50 * time.Millisecond, mode: Raw}
+	for _, opt := range opts {
+		opt(&o)
 	}
+	return o
+}
 
-	*sz = 2
-
-	switch ib.b[1] {
-	case byte(ESC):
-		return Event{KeySpecial, ESC, nil}
-	case 127:
-		return Event{KeySpecial, AltBS, nil}
-	case 91, 79: // [, O
-		if len(ib.b) < 3 {
-			if ib.b[1] == '[' {
-				return Event{KeySpecial, AltOpenBracket, nil}
-			} else if ib.b[1] == 'O' {
-				return Event{KeySpecial, AltO, nil}
-			}
-			return debugEv(ib.b)
-		}
-		*sz = 3
-		switch ib.b[2] {
-		case 65:
-			return Event{KeySpecial, Up, nil}
-		case 66:
-			return Event{KeySpecial, Down, nil}
-		case 67:
-			return Event{KeySpecial, Right, nil}
-		case 68:
-			return Event{KeySpecial, Left, nil}
-		case 90:
-			return Event{KeySpecial, BTab, nil}
-		case 72:
-			return Event{KeySpecial, Home, nil}
-		case 70:
-			return Event{KeySpecial, End, nil}
-		case 77:
-			return ib.mouseSequence(sz)
-		case 80:
-			return Event{KeySpecial, F1, nil}
-		case 81:
-			return Event{KeySpecial, F2, nil}
-		case 82:
-			return Event{KeySpecial, F3, nil}
-		case 49, 50, 51, 52, 53, 54:
-			if len(ib.b) < 4 {
-				return debugEv(ib.b)
-			}
-			*sz = 4
-			switch ib.b[2] {
-			case 50:
-				if len(ib.b) == 5 && ib.b[4] == 126 {
-					*sz = 5
-					switch ib.b[3] {
-					case 48:
-						return Event{KeySpecial, F9, nil}
-					case 49:
-						return Event{KeySpecial, F10, nil}
-					// @todo: WTF does 50 mean?
-					case 51:
-						return Event{KeySpecial, F11, nil}
-					case 52:
-						return Event{KeySpecial, F12, nil}
-					}
-				} else if ib.b[3] == '0' && (ib.b[4] == '0' || ib.b[4] == '1') && ib.b[5] == '~' {
-					// bracketed paste mode. \e[200~ .. \e[201~
-					//discard seq from buffer
-					ib.b = ib.b[6:]
-					*sz = 0
-					return Event{KeySpecial, Null, nil}
-				}
-				return debugEv(ib.b)
-			case 51:
-				if len(ib.b) >= 6 && ib.b[3] == 59 && ib.b[4] == 51 && ib.b[5] == '~' {
-					*sz = 6
-					return Event{KeySpecial, AltDel, nil}
-				}
-				return Event{KeySpecial, Del, nil}
-			case 52:
-				return Event{KeySpecial, End, nil}
-			case 53:
-				return Event{KeySpecial, PgUp, nil}
-			case 54:
-				return Event{KeySpecial, PgDn, nil}
-			case 49: //'1'
-				switch ib.b[3] {
-				case 126:
-					return Event{KeySpecial, Home, nil}
-				case 53, 55, 56, 57:
-					if len(ib.b) == 5 && ib.b[4] == 126 {
-						*sz = 5
-						switch ib.b[3] {
-						case 53:
-							return Event{KeySpecial, F5, nil}
-						case 55:
-							return Event{KeySpecial, F6, nil}
-						case 56:
-							return Event{KeySpecial, F7, nil}
-						case 57:
-							return Event{KeySpecial, F8, nil}
-						}
-					}
-					return debugEv(ib.b)
-				case ';': //59
-					if len(ib.b) != 6 {
-						return debugEv(ib.b)
-					}
-					*sz = 6
-					if ib.b[4] != '2' && ib.b[4] != '5' {
-						return debugEv(ib.b)
-					}
-					if ib.b[5] < 'A' || ib.b[5] > 'D' {
-						return debugEv(ib.b)
-					}
-
-					//ESC[1;2A == shift-up
-					//ESC[1;5A == ctrl-up
-					k := SUp
-					if ib.b[4] == '5' { // move to up Ctrl*
-						k += 4
-					}
-					k += rune(int(ib.b[5]) - int('A')) // set arrow direction
-					return Event{KeySpecial, k, nil}
-				}
-			}
-		}
-	}
+// How long to wait for the rest of an escape sequence, before deciding a lone ESC was the ESC key
+// (like vim's ttimeoutlen). Defaults to 50ms. Shorter makes ESC snappier, but risks splitting up
+// sequences arriving over slow connections
+func EscTimeout(d time.Duration) Option { return func(o *options) { o.escTimeout = d } }
 
-	// ESC-0 ~ ESC-26 == ctrl-alt-[key]
-	if ib.b[1] >= 1 && ib.b[1] <= 'z'-'a'+1 {
-		return Event{KeySpecial, rune(int(CtrlAlta) + int(ib.b[1]) - 1), nil}
-	}
+// How the terminal is set up while reading input
+type Mode uint8
 
-	// ESC-32 ~ ESC-126 == alt-[key]
-	if Printable(int(ib.b[1])) {
-		return Event{KeySpecial, rune(ib.b[1]), nil}
-	}
+const (
+	// Nothing is done for you: no echo, no line editing, Ctrl-C and Ctrl-Z arrive as keys,
+	// and output needs \r\n to start a new line. The default, and what full-screen apps want
+	Raw Mode = iota
+	// Keys arrive as they're typed, without echo. But Ctrl-C and Ctrl-Z still send signals, and output
+	// is processed as usual, so fmt.Println works. For prompts and other line-oriented programs
+	Cbreak
+)
 
-	return debugEv(ib.b)
-}
+// Sets up the terminal in mode m, instead of Raw
+func InputMode(m Mode) Option { return func(o *options) { o.mode = m } }
 
-// mouse stuff
+// termios(3) flags, e.g. uint64(unix.ISIG) in Lflag
+type TermFlags struct {
+	Iflag, Oflag, Cflag, Lflag uint64
+}
 
-func debugEv(buf []byte) Event {
-	b := make([]byte, len(buf))
-	copy(b, buf)
-	return Event{EventInvalid, 0, &MouseEvent{0, 0, 0, false, false, false, false, b}}
+// For anything Raw and Cbreak don't cover: after setting up the Mode, the flags in clear are turned off,
+// then those in set turned on. Not supported on windows
+func TermiosFlags(clear, set TermFlags) Option {
+	return func(o *options) { o.clear, o.set = clear, set }
 }
 
-// https://www.xfree86.org/current/ctlseqs.html#Mouse%20Tracking
-// \x1b[M<button><x+33><y+33>
-func (ib *inputBuf) mouseSequence(sz *int) Event {
-	b := make([]byte, len(ib.b))
-	copy(b, ib.b)
-	if len(ib.b) < 6 {
-		fmt.Printf("short mouse seq: %v\n", ib.b)
-		return debugEv(ib.b)
-	}
-	*sz = 6
+/*
+Decodes input from any io.Reader into the same stream of Events that GetInput produces.
+Useful for driving an app from a pipe, a network connection or a test fixture.
 
-	evCode := int(b[3] - 32)
+Unlike GetInput, no terminal state is touched. The channel is closed when the reader returns an error
+(including io.EOF), or when ctx is done. A Read that's already blocked can't be interrupted though,
+the reading goroutine leaves once it returns
+*/
+func Events(ctx context.Context, r io.Reader, opts ...Option) <-chan Event {
+	o := newOptions(opts)
+	ch := make(chan Event, 1000)
+	chunks := make(chan []byte)
 
-	bNum := evCode & 0x3 // low two bits, 00=MB1, 01=MB2, 10=MB3, 11=Release
-	if evCode&(1<<6) != 0 {
-		bNum += 4 // scroll buttons set a high bit (+32)
+	go func() { // reads block, so they happen off to the side
+		defer close(chunks)
+		for {
+			b := make([]byte, 256)
+			n, err := r.Read(b)
+			if n > 0 {
+				select {
+				case chunks <- b[:n]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(ch)
+
+		var d Decoder
+		var timeout <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case b, ok := <-chunks:
+				if !ok {
+					send(ctx, ch, d.Flush())
+					return
+				}
+				if !send(ctx, ch, d.Feed(b)) {
+					return
+				}
+				timeout = nil
+				if d.Pending() {
+					timeout = time.After(o.escTimeout)
+				}
+			case <-timeout:
+				timeout = nil
+				if !send(ctx, ch, d.Flush()) {
+					return
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// delivers events in order. False if ctx was done first
+func send(ctx context.Context, ch chan<- Event, evs []Event) bool {
+	for _, ev := range evs {
+		select {
+		case ch <- ev:
+		case <-ctx.Done():
+			return false
+		}
 	}
-	shift := evCode&(1<<2) != 0  // 4
-	meta := evCode&(1<<3) != 0   // 8
-	ctrl := evCode&(1<<4) != 0   // 16
-	motion := evCode&(1<<5) != 0 //32, motion indicator
-
-	x := int(ib.b[4] - 33)
-	y := int(ib.b[5] - 33) // - yoffset if any
-	return Event{Mouse, 0, &MouseEvent{y, x, bNum, shift, meta, ctrl, motion, b}}
+	return true
 }
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func collect(ch <-chan Event) []Event {
+	var evs []Event
+	for ev := range ch {
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+func TestEvents(t *testing.T) {
+	evs := collect(Events(context.Background(), strings.NewReader("a\x1b[A\x1b")))
+	assert.Equal(t, []Event{
+		{Type: KeyPrint, Key: 'a'},
+		{Type: KeySpecial, Key: Up},
+		{Type: KeySpecial, Key: ESC},
+	}, evs)
+}
+
+func TestEvents_EscTimeout(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	ch := Events(context.Background(), r)
+
+	w.Write([]byte("\x1b"))
+	select {
+	case ev := <-ch:
+		assert.Equal(t, Event{Type: KeySpecial, Key: ESC}, ev)
+	case <-time.After(time.Second):
+		t.Fatal("lone ESC never flushed")
+	}
+}
+
+func TestEvents_Cancel(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := Events(ctx, r)
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed on cancel")
+	}
+}
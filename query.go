@@ -0,0 +1,310 @@
+package tui
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pzl/tui/ansi"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Returned by queries when the terminal didn't reply in time. Usually, that means it doesn't support the query
+var ErrNoReply = errors.New("no reply from the terminal")
+
+/*
+Asks the terminal something, e.g. where the cursor is (CSI 6n), or what it supports. request is written to
+the terminal on fd, and the first reply that match accepts is returned, as the whole escape sequence.
+Gives up with ErrNoReply after timeout.
+
+Replies arrive on the input stream, mixed in with keys. If GetInput or a Terminal is reading fd, the reply
+is picked out of the stream there, and other input carries on arriving as events. Otherwise fd is read
+directly until the reply comes, and any other input in the meantime is lost.
+
+Replies that no one waits for, say after a timeout, arrive as Reply events.
+On windows, there's no timeout when nothing is reading fd
+*/
+func Query(fd int, request string, match func(reply string) bool, timeout time.Duration) (string, error) {
+	c, reply := first(match)
+	err := query(fd, request, c, timeout)
+	return *reply, err
+}
+
+// Like the package level Query, on this Terminal
+func (t *Terminal) Query(request string, match func(reply string) bool, timeout time.Duration) (string, error) {
+	c, reply := first(match)
+	err := t.input.r.query(t.Out, request, c, timeout)
+	return *reply, err
+}
+
+/*
+Sees each reply that comes in while waiting on a query. Returns whether it took the reply (so it isn't
+sent on as an event, or to another query) and whether it's done waiting.
+Lets one request ask several things at once
+*/
+type collector func(reply string) (taken, done bool)
+
+// a collector for the first reply that matches, stored in reply
+func first(match func(string) bool) (c collector, reply *string) {
+	reply = new(string)
+	return func(s string) (bool, bool) {
+		if match(s) {
+			*reply = s
+			return true, true
+		}
+		return false, false
+	}, reply
+}
+
+func query(fd int, request string, c collector, timeout time.Duration) error {
+	if r := readers.find(fd); r != nil {
+		return r.query(fdWriter(r.fd), request, c, timeout) // r.fd: the controlling terminal, if fd isn't a terminal
+	}
+	return queryDirect(fd, request, c, timeout)
+}
+
+// Where the cursor is, 1-based: x is the row, y the column (like the package level CursorPosition).
+// Works while the event loop is running
+func (t *Terminal) CursorPosition() (x int, y int, err error) {
+	reply, err := t.Query(ansi.CursorPosition.String(), isCursorReply, queryTimeout)
+	return cursorXY(reply, err)
+}
+
+// asks the terminal on stdin (or the controlling terminal), for the helpers built on query
+func askStdin(request string, c collector) error {
+	return query(int(os.Stdin.Fd()), request, c, queryTimeout)
+}
+
+func (t *Terminal) ask(request string, c collector) error {
+	return t.input.r.query(t.Out, request, c, queryTimeout)
+}
+
+/*
+asks something with OSC <code>;?, returning what comes after "<code>;" in the reply, without the terminator.
+DA1 goes after it, so a terminal that doesn't know the query gives itself away right away,
+instead of after a timeout
+*/
+func oscQuery(ask func(string, collector) error, code string) (string, error) {
+	prefix := "\x1b]" + code + ";"
+	var reply string
+	var replied bool
+	err := ask(prefix+"?\x1b\\"+"\x1b[c", func(s string) (bool, bool) {
+		switch {
+		case strings.HasPrefix(s, prefix):
+			replied = true
+			reply = strings.TrimSuffix(strings.TrimSuffix(s[len(prefix):], "\a"), "\x1b\\")
+			return true, false
+		case strings.HasPrefix(s, "\x1b[?") && strings.HasSuffix(s, "c"):
+			return true, true
+		}
+		return false, false
+	})
+	if err != nil {
+		return "", err
+	}
+	if !replied {
+		return "", ErrNoReply
+	}
+	return reply, nil
+}
+
+// how long the helpers built on Query wait for a reply
+const queryTimeout = time.Second
+
+func isCursorReply(s string) bool {
+	_, _, ok := cursorReport(s)
+	return ok
+}
+
+func cursorXY(reply string, err error) (x int, y int, _ error) {
+	if err != nil {
+		return 0, 0, err
+	}
+	row, col, _ := cursorReport(reply)
+	return row, col, nil
+}
+
+// a query waiting on a reader for its reply
+type waiter struct {
+	c    collector
+	done chan struct{}
+}
+
+func (r *reader) query(w io.Writer, request string, c collector, timeout time.Duration) error {
+	wt := &waiter{c: c, done: make(chan struct{})}
+	r.mu.Lock()
+	r.waiting = append(r.waiting, wt)
+	r.mu.Unlock()
+	defer r.forget(wt)
+
+	if strings.HasSuffix(request, ansi.CursorPosition.String()) {
+		atomic.AddInt32(&r.cprs, 1)
+		defer func() {
+			select {
+			case <-wt.done:
+			default: // the report isn't coming after all, so one for row 1 is a key again
+				atomic.AddInt32(&r.cprs, -1)
+			}
+		}()
+	}
+	if _, err := io.WriteString(w, request); err != nil {
+		return err
+	}
+
+	select {
+	case <-wt.done:
+		return nil
+	case <-time.After(timeout):
+		return ErrNoReply
+	case <-r.done:
+		return errors.New("input has stopped")
+	}
+}
+
+func (r *reader) forget(wt *waiter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, w := range r.waiting {
+		if w == wt {
+			r.waiting = append(r.waiting[:i], r.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// hands replies to the queries waiting for them. Returns the events left over
+func (r *reader) answer(evs []Event) []Event {
+	out := evs[:0]
+	for _, ev := range evs {
+		if ev.Type != Reply || !r.deliver(ev.Text) {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func (r *reader) deliver(reply string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, w := range r.waiting {
+		taken, done := w.c(reply)
+		if done {
+			close(w.done)
+			r.waiting = append(r.waiting[:i], r.waiting[i+1:]...)
+			return taken
+		}
+		if taken {
+			return true
+		}
+	}
+	return false
+}
+
+// the readers running, so queries can find the one reading their fd
+var readers = &registry{}
+
+type registry struct {
+	mu sync.Mutex
+	rs []*reader
+}
+
+func (g *registry) add(r *reader) {
+	g.mu.Lock()
+	g.rs = append(g.rs, r)
+	g.mu.Unlock()
+}
+
+func (g *registry) remove(r *reader) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, x := range g.rs {
+		if x == r {
+			g.rs = append(g.rs[:i], g.rs[i+1:]...)
+			return
+		}
+	}
+}
+
+// the reader on fd. Or for an fd that isn't a terminal, the one reading the controlling terminal in its place
+func (g *registry) find(fd int) *reader {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ctty := !IsTTY(uintptr(fd))
+	for _, r := range g.rs {
+		if r.fd == fd || (ctty && r.ctty) {
+			return r
+		}
+	}
+	return nil
+}
+
+// when nothing else is reading fd: read it here, until the reply comes
+func queryDirect(fd int, request string, c collector, timeout time.Duration) error {
+	if !IsTTY(uintptr(fd)) {
+		tty, err := OpenTTY()
+		if err != nil {
+			return errors.New("input is not a TTY")
+		}
+		defer tty.Close()
+		fd = int(tty.Fd())
+	}
+	st, err := terminal.GetState(fd)
+	if err != nil {
+		return err
+	}
+	defer terminal.Restore(fd, st)
+	if err := setMode(fd, newOptions(nil)); err != nil { // so the reply isn't echoed, or held for a newline
+		return err
+	}
+
+	var d Decoder
+	if strings.HasSuffix(request, ansi.CursorPosition.String()) {
+		d.ExpectCursorReport()
+	}
+	if _, err := io.WriteString(fdWriter(fd), request); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(timeout)
+	b := make([]byte, 256)
+	for {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return ErrNoReply
+		}
+		if !canInterrupt {
+			wait = -1
+		}
+		ready, _, err := waitRead(fd, -1, wait)
+		if err != nil {
+			return err
+		}
+		if !ready {
+			continue
+		}
+		n, err := sysRead(fd, b)
+		if err != nil || n == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return err
+		}
+		for _, ev := range d.Feed(b[:n]) {
+			if ev.Type != Reply {
+				continue
+			}
+			if _, done := c(ev.Text); done {
+				return nil
+			}
+		}
+	}
+}
+
+// writes straight to a file descriptor
+type fdWriter int
+
+func (fd fdWriter) Write(p []byte) (int, error) { return sysWrite(int(fd), p) }
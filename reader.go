@@ -0,0 +1,204 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// reads and decodes input from a terminal, all in one long-lived goroutine.
+// It blocks in poll(2) until input arrives, rather than spinning on non-blocking reads
+type reader struct {
+	fd      int
+	timeout time.Duration // see EscTimeout
+	dec     Decoder
+	buf     []byte
+
+	// self-pipe. Written to when the reader needs waking up out of poll(2)
+	wakeR, wakeW *os.File
+
+	pauses chan bool     // true asks the woken reader to stop reading fd, false lets it carry on
+	done   chan struct{} // closed when run returns
+
+	ctty    bool  // fd is the controlling terminal, opened in place of one that wasn't a terminal
+	cprs    int32 // cursor position reports to expect, less those given up on. See Decoder.ExpectCursorReport
+	mu      sync.Mutex
+	waiting []*waiter // queries waiting for their reply
+}
+
+func newReader(fd int, o options) (*reader, error) {
+	wr, ww, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	return &reader{
+		fd:      fd,
+		timeout: o.escTimeout,
+		buf:     make([]byte, 4096),
+		wakeR:   wr,
+		wakeW:   ww,
+		pauses:  make(chan bool),
+		done:    make(chan struct{}),
+		ctty:    o.ctty,
+	}, nil
+}
+
+// input being read in the background, see startInput
+type input struct {
+	ch     chan Event
+	r      *reader
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex // guards sending on ch, against closing it
+	closed bool
+}
+
+/*
+Starts reading fd in the background, along with watching for terminal size changes.
+Events arrive on in.ch, which is closed once everything has stopped. That happens when ctx is done,
+when input ends, or on stop
+*/
+func startInput(ctx context.Context, fd int, o options) (*input, error) {
+	r, err := newReader(fd, o)
+	if err != nil {
+		return nil, err
+	}
+	in := &input{ch: make(chan Event, 1000), r: r}
+	in.ctx, in.cancel = context.WithCancel(ctx)
+
+	readers.add(r)
+	in.wg.Add(2)
+	go func() {
+		defer in.wg.Done()
+		defer in.cancel() // input ended, so stop everything else
+		defer readers.remove(r)
+		r.run(in.ctx, in.ch)
+	}()
+	go func() {
+		defer in.wg.Done()
+		watchResize(in.ctx, fd, in.ch)
+	}()
+	go func() {
+		in.wg.Wait()
+		in.mu.Lock()
+		in.closed = true
+		close(in.ch)
+		in.mu.Unlock()
+	}()
+	return in, nil
+}
+
+// does the same as cancelling ctx, and waits until it's done
+func (in *input) stop() {
+	in.cancel()
+	if canInterrupt {
+		in.wg.Wait()
+	}
+}
+
+// stops reading fd until resume, e.g. while another program has the terminal. Returns once the
+// reader has let go. Resizes are still reported.
+// Where reads can't be interrupted (windows), the reader carries on
+func (in *input) pause() {
+	if !canInterrupt {
+		return
+	}
+	in.r.wakeW.Write([]byte{0})
+	select {
+	case in.r.pauses <- true:
+	case <-in.r.done:
+	}
+}
+
+func (in *input) resume() {
+	if !canInterrupt {
+		return
+	}
+	select {
+	case in.r.pauses <- false:
+	case <-in.r.done:
+	}
+}
+
+// adds an event to the stream, from outside the reader. Dropped if input has stopped
+func (in *input) send(ev Event) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	if !in.closed {
+		send(in.ctx, in.ch, []Event{ev})
+	}
+}
+
+func (r *reader) run(ctx context.Context, ch chan<- Event) {
+	defer close(r.done)
+	defer r.wakeR.Close()
+	defer r.wakeW.Close()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+	go func() {
+		select {
+		case <-ctx.Done():
+			r.wakeW.Write([]byte{0})
+		case <-stopped:
+		}
+	}()
+
+	wake := int(r.wakeR.Fd())
+	for ctx.Err() == nil {
+		wait := time.Duration(-1) // nothing held back, wait as long as it takes
+		if r.dec.Pending() {
+			wait = r.timeout
+		}
+
+		ready, woken, err := waitRead(r.fd, wake, wait)
+		if err != nil {
+			return
+		}
+		if woken {
+			if ctx.Err() != nil || !r.paused(ctx) {
+				return
+			}
+			continue
+		}
+		if !ready { // the rest of the sequence never came
+			if !send(ctx, ch, r.answer(r.dec.Flush())) {
+				return
+			}
+			continue
+		}
+
+		n, err := sysRead(r.fd, r.buf)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			continue
+		}
+		if err != nil || n == 0 {
+			send(ctx, ch, r.answer(r.dec.Flush()))
+			return
+		}
+		r.dec.expectCursorReports(int(atomic.SwapInt32(&r.cprs, 0)))
+		if !send(ctx, ch, r.answer(r.dec.Feed(r.buf[:n]))) {
+			return
+		}
+	}
+}
+
+// a wake up that wasn't from ctx is a pause. Waits for the resume. False if ctx was done first
+func (r *reader) paused(ctx context.Context) bool {
+	r.wakeR.Read(make([]byte, 1))
+	for {
+		select {
+		case p := <-r.pauses:
+			if !p {
+				return true
+			}
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
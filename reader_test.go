@@ -0,0 +1,129 @@
+package tui
+
+import (
+	"context"
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pipeReader(t testing.TB, opts ...Option) (*os.File, <-chan Event, context.CancelFunc) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := startInput(context.Background(), int(pr.Fd()), newOptions(opts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pw, in.ch, func() {
+		in.stop()
+		pw.Close()
+		pr.Close()
+	}
+}
+
+func TestReader(t *testing.T) {
+	w, ch, stop := pipeReader(t)
+	defer stop()
+
+	w.Write([]byte("a\x1b[A"))
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'a'}, <-ch)
+	assert.Equal(t, Event{Type: KeySpecial, Key: Up}, <-ch)
+}
+
+func TestReader_EscTimeout(t *testing.T) {
+	w, ch, stop := pipeReader(t, EscTimeout(10*time.Millisecond))
+	defer stop()
+
+	start := time.Now()
+	w.Write([]byte("\x1b"))
+	assert.Equal(t, Event{Type: KeySpecial, Key: ESC}, <-ch)
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+func TestReader_Stop(t *testing.T) {
+	w, ch, stop := pipeReader(t)
+	defer w.Close()
+
+	before := runtime.NumGoroutine()
+	stop() // returns only once the reader is gone
+	_, ok := <-ch
+	assert.False(t, ok, "channel is closed")
+	assert.True(t, runtime.NumGoroutine() < before)
+}
+
+func TestReader_Cancel(t *testing.T) {
+	pr, pw, _ := os.Pipe()
+	defer pr.Close()
+	defer pw.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in, _ := startInput(ctx, int(pr.Fd()), newOptions(nil))
+	ch := in.ch
+	cancel()
+
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed on cancel")
+	}
+}
+
+func TestReader_EOF(t *testing.T) {
+	w, ch, stop := pipeReader(t)
+	defer stop()
+
+	w.Write([]byte("a"))
+	w.Close()
+	assert.Equal(t, []Event{{Type: KeyPrint, Key: 'a'}}, collect(ch))
+}
+
+func goroutinesCreated() uint64 {
+	s := []metrics.Sample{{Name: "/sched/goroutines-created:goroutines"}}
+	metrics.Read(s)
+	if s[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return s[0].Value.Uint64()
+}
+
+// a keystroke, all the way from the fd to the channel
+func BenchmarkReader_Keystroke(b *testing.B) {
+	w, ch, stop := pipeReader(b)
+	defer stop()
+	key := []byte("a")
+
+	created := goroutinesCreated()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.Write(key)
+		<-ch
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(goroutinesCreated()-created)/float64(b.N), "goroutines/op")
+}
+
+func BenchmarkDecoder_Keystroke(b *testing.B) {
+	var d Decoder
+	key := []byte("a")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.Feed(key)
+	}
+}
+
+func BenchmarkDecoder_ModifiedArrow(b *testing.B) {
+	var d Decoder
+	key := []byte("\x1b[1;5A")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.Feed(key)
+	}
+}
@@ -2,7 +2,87 @@
 
 package tui
 
-import "syscall"
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-func sysRead(fd int, p []byte) (int, error)   { return syscall.Read(fd, p) }
-func setNonBlock(fd int, nonblock bool) error { return syscall.SetNonblock(fd, nonblock) }
+	"golang.org/x/sys/unix"
+)
+
+func sysRead(fd int, p []byte) (int, error)  { return syscall.Read(fd, p) }
+func sysWrite(fd int, p []byte) (int, error) { return syscall.Write(fd, p) }
+
+// puts fd in the mode o asks for
+func setMode(fd int, o options) error {
+	tio, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+
+	switch o.mode {
+	case Cbreak:
+		tio.Iflag &^= unix.ICRNL // so Enter is still CtrlM
+		tio.Lflag &^= unix.ECHO | unix.ICANON
+	default: // like cfmakeraw(3)
+		tio.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+		tio.Oflag &^= unix.OPOST
+		tio.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+		tio.Cflag &^= unix.CSIZE | unix.PARENB
+		tio.Cflag |= unix.CS8
+	}
+	tio.Cc[unix.VMIN] = 1
+	tio.Cc[unix.VTIME] = 0
+
+	tio.Iflag = tio.Iflag&^tcflag(o.clear.Iflag) | tcflag(o.set.Iflag)
+	tio.Oflag = tio.Oflag&^tcflag(o.clear.Oflag) | tcflag(o.set.Oflag)
+	tio.Cflag = tio.Cflag&^tcflag(o.clear.Cflag) | tcflag(o.set.Cflag)
+	tio.Lflag = tio.Lflag&^tcflag(o.clear.Lflag) | tcflag(o.set.Lflag)
+
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, tio)
+}
+
+// the controlling terminal
+const ttyPath = "/dev/tty"
+
+// a blocked reader can always be woken through its self-pipe
+const canInterrupt = true
+
+// waits up to timeout for fd to have input to read, or for wake to be written to.
+// A negative timeout waits forever
+func waitRead(fd, wake int, timeout time.Duration) (ready, woken bool, err error) {
+	ms := -1
+	if timeout >= 0 {
+		ms = int(timeout / time.Millisecond)
+	}
+	fds := []unix.PollFd{
+		{Fd: int32(fd), Events: unix.POLLIN},
+		{Fd: int32(wake), Events: unix.POLLIN},
+	}
+	for {
+		_, err := unix.Poll(fds, ms)
+		if err == unix.EINTR { // e.g. SIGWINCH
+			continue
+		}
+		return fds[0].Revents != 0, fds[1].Revents != 0, err
+	}
+}
+
+// terminal size changes are signalled with SIGWINCH
+func notifyResize(c chan<- os.Signal) { signal.Notify(c, syscall.SIGWINCH) }
+
+// sends sig to ourselves
+func raise(sig os.Signal) { syscall.Kill(os.Getpid(), sig.(syscall.Signal)) }
+
+// job control
+var (
+	sigStop    os.Signal = syscall.SIGTSTP
+	sigCont    os.Signal = syscall.SIGCONT
+	jobSignals           = []os.Signal{sigStop, sigCont}
+)
+
+// stops our process group, the way Ctrl-Z would have outside raw mode.
+// Once SIGTSTP has been caught, the runtime keeps catching it even after signal.Reset, so it can't
+// stop us anymore. SIGSTOP always can
+func stopProcess() error { return syscall.Kill(0, syscall.SIGSTOP) }
@@ -3,10 +3,60 @@
 package tui
 
 import (
+	"errors"
+	"os"
 	"syscall"
+	"time"
+
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/sys/windows"
 )
 
-func sysRead(fd int, p []byte) (int, error) { return syscall.Read(syscall.Handle(uintptr(fd)), p) }
-func setNonBlock(fd int, nonblock bool) error {
-	return syscall.SetNonblock(syscall.Handle(uintptr(fd)), nonblock)
+func sysRead(fd int, p []byte) (int, error)  { return syscall.Read(syscall.Handle(uintptr(fd)), p) }
+func sysWrite(fd int, p []byte) (int, error) { return syscall.Write(syscall.Handle(uintptr(fd)), p) }
+
+// puts fd in the mode o asks for. Consoles have modes rather than termios flags, so those can't be set
+func setMode(fd int, o options) error {
+	if o.clear != (TermFlags{}) || o.set != (TermFlags{}) {
+		return errors.New("termios flags are not supported on windows")
+	}
+	if _, err := terminal.MakeRaw(fd); err != nil {
+		return err
+	}
+	if o.mode != Cbreak {
+		return nil
+	}
+	var m uint32
+	h := windows.Handle(fd)
+	if err := windows.GetConsoleMode(h, &m); err != nil {
+		return err
+	}
+	return windows.SetConsoleMode(h, m|windows.ENABLE_PROCESSED_INPUT) // Ctrl-C
+}
+
+// the console's input
+const ttyPath = "CONIN$"
+
+// a read blocked on a console handle can't be woken up. Stopping input won't wait for it
+const canInterrupt = false
+
+// console handles can't be polled here. Reads just block, and anything held back
+// waiting for the rest of an escape sequence is decoded right away
+func waitRead(fd, wake int, timeout time.Duration) (ready, woken bool, err error) {
+	return timeout < 0, false, nil
 }
+
+// no SIGWINCH on windows. Resize events are not sent
+func notifyResize(c chan<- os.Signal) {}
+
+// signals can't be sent to ourselves. Exit the way an interrupted program would
+func raise(sig os.Signal) { os.Exit(2) }
+
+// no job control on windows
+var (
+	sigStop    os.Signal
+	sigCont    os.Signal
+	jobSignals []os.Signal
+)
+
+func stopProcess() error { return errors.New("suspending is not supported on windows") }
@@ -0,0 +1,382 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/pzl/tui/ansi"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+/*
+A Terminal is a session on a terminal, for full-screen apps. It takes care of the setup and teardown
+otherwise done by hand: raw (or cbreak) input, the alternate screen, mouse reporting, bracketed paste and the cursor.
+
+Every change made to the terminal is recorded, and Close undoes all of them, in reverse order.
+Close also runs when the program gets SIGTERM, SIGINT or SIGHUP, so the user's shell isn't left in raw
+mode with mouse reporting on. To cover panics, defer Close right after Open (and defer Recover in any other
+goroutine that might panic).
+
+Raw mode means Ctrl-Z arrives as a CtrlZ key rather than stopping the program. Call Suspend on it
+to behave like other programs under job control. SIGTSTP sent some other way suspends too.
+To hand the terminal to another program, like $EDITOR, use Exec.
+
+Output commands can be issued on the Terminal directly, they go to the terminal's output:
+
+	t, err := tui.Open(tui.AltScreen(), tui.MouseReporting(ansi.MotionOnDrag))
+	if err != nil {
+		panic(err)
+	}
+	defer t.Close()
+
+	t.ClearAll()
+	for ev := range t.Events() {
+		...
+	}
+*/
+type Terminal struct {
+	*ansi.Writer
+	In  *os.File
+	Out *os.File
+
+	input  *input
+	sigs   chan os.Signal
+	done   chan struct{} // closed by Close
+	opened []*os.File    // the controlling terminal, when In or Out weren't terminals
+
+	mu        sync.Mutex
+	modes     []mode // changes made to the terminal, in the order they were made
+	closed    bool
+	suspended bool // every change is undone, and input paused. By Suspend or Exec
+	execing   bool
+}
+
+// a change made to the terminal, and how to undo it
+type mode struct {
+	set   func() error
+	reset func() error
+}
+
+// A TermOption chooses what Open sets up on the terminal
+type TermOption func(*termOptions)
+
+type termOptions struct {
+	in, out *os.File
+	alt     bool
+	mouse   bool
+	motion  ansi.MouseMotion
+	paste   bool
+	hide    bool
+	cursor  ansi.CursorCmd
+	input   []Option
+}
+
+// Use these files for input and output, instead of Stdin and Stdout.
+// Either one that isn't a terminal is swapped for the controlling terminal (see OpenTTY)
+func Files(in, out *os.File) TermOption {
+	return func(o *termOptions) { o.in, o.out = in, out }
+}
+
+// Switch to the alternate screen, leaving the normal one as it was
+func AltScreen() TermOption { return func(o *termOptions) { o.alt = true } }
+
+// Turn on mouse reporting, with SGR coordinates
+func MouseReporting(m ansi.MouseMotion) TermOption {
+	return func(o *termOptions) { o.mouse, o.motion = true, m }
+}
+
+// Turn on bracketed paste, so pastes arrive as Paste events
+func BracketedPaste() TermOption { return func(o *termOptions) { o.paste = true } }
+
+// Hide the cursor
+func HideCursor() TermOption { return func(o *termOptions) { o.hide = true } }
+
+// Set the cursor shape, e.g. ansi.CursorIBlink. It's put back to the terminal's default on Close
+func CursorStyle(c ansi.CursorCmd) TermOption { return func(o *termOptions) { o.cursor = c } }
+
+// Options for reading input, like EscTimeout or InputMode
+func InputOptions(opts ...Option) TermOption {
+	return func(o *termOptions) { o.input = append(o.input, opts...) }
+}
+
+// Opens a session on the terminal, with whatever the options ask for. On error, anything already changed is undone
+func Open(opts ...TermOption) (*Terminal, error) {
+	o := termOptions{in: os.Stdin, out: os.Stdout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	t := &Terminal{
+		sigs: make(chan os.Signal, 4),
+		done: make(chan struct{}),
+	}
+	inOpts := newOptions(o.input)
+	var opened bool
+	if t.In, opened = ttyOr(o.in); opened {
+		t.opened = append(t.opened, t.In)
+		inOpts.ctty = true
+	}
+	if t.Out, opened = ttyOr(o.out); opened {
+		t.opened = append(t.opened, t.Out)
+	}
+	t.Writer = ansi.NewWriter(t.Out)
+	fd := int(t.In.Fd())
+
+	st, err := terminal.GetState(fd)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	if err := t.set(func() error {
+		return setMode(fd, inOpts)
+	}, func() error {
+		return terminal.Restore(fd, st)
+	}); err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	if o.alt {
+		t.setOutput(func() { t.Screen(ansi.Alt) }, func() { t.Screen(ansi.Normal) })
+	}
+	if o.mouse {
+		t.setOutput(func() { t.MouseEnable(o.motion) }, func() { t.MouseDisable(o.motion) })
+		t.setOutput(func() { t.MouseSGR(true) }, func() { t.MouseSGR(false) })
+	}
+	if o.paste {
+		t.setOutput(func() { t.BracketedPaste(true) }, func() { t.BracketedPaste(false) })
+	}
+	if o.hide {
+		t.setOutput(t.CursorHide, t.CursorShow)
+	}
+	if o.cursor != "" {
+		t.setOutput(func() { fmt.Fprint(t.Out, o.cursor) }, t.CursorBlinker)
+	}
+
+	in, err := startInput(context.Background(), fd, inOpts)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.input = in
+
+	signal.Notify(t.sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	signal.Notify(t.sigs, jobSignals...)
+	go t.handleSignals()
+
+	return t, nil
+}
+
+// Input events: keys, mouse, paste, resizes. Closed once the Terminal is
+func (t *Terminal) Events() <-chan Event { return t.input.ch }
+
+// Undoes every change made to the terminal, in reverse order. Safe to call more than once
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	signal.Stop(t.sigs)
+	close(t.done)
+
+	if t.input != nil {
+		t.input.stop()
+	}
+	var err error
+	if !t.suspended { // or else already undone
+		err = t.reset()
+	}
+	for _, f := range t.opened {
+		f.Close()
+	}
+	return err
+}
+
+/*
+Puts the terminal back the way it was found and stops the program (and the rest of its process group),
+like Ctrl-Z would have in a shell. When the program is continued (fg or bg), everything is set up again
+and a Resume event is sent, so the screen can be redrawn.
+
+Suspend returns without waiting for the program to be continued.
+Not supported on windows
+*/
+func (t *Terminal) Suspend() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || t.suspended {
+		return nil
+	}
+	t.input.pause()
+	t.suspended = true
+	err := t.reset()
+	if e := stopProcess(); e != nil {
+		t.suspended = false
+		t.apply()
+		t.input.resume()
+		return e
+	}
+	return err
+}
+
+/*
+Runs cmd with the terminal handed over to it, e.g. to open $EDITOR on a file. Input isn't read, and every
+change made to the terminal is undone while cmd runs. Afterwards they're all made again, and a
+Resume event is sent so the screen can be redrawn.
+
+cmd's Stdin, Stdout and Stderr default to the terminal, where not already set.
+Like system(3), Ctrl-C is left to cmd, rather than closing the Terminal
+*/
+func (t *Terminal) Exec(cmd *exec.Cmd) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return errors.New("terminal is closed")
+	}
+	if t.suspended {
+		t.mu.Unlock()
+		return errors.New("terminal is in use")
+	}
+	t.input.pause()
+	t.suspended, t.execing = true, true
+	t.reset()
+	t.mu.Unlock()
+
+	if cmd.Stdin == nil {
+		cmd.Stdin = t.In
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = t.Out
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = t.Out
+	}
+	err := cmd.Run()
+
+	t.mu.Lock()
+	t.execing = false
+	if t.closed {
+		t.mu.Unlock()
+		return err
+	}
+	t.suspended = false
+	if e := t.apply(); e != nil && err == nil {
+		err = e
+	}
+	t.input.resume()
+	t.mu.Unlock()
+
+	w, h := TermSize(int(t.Out.Fd()))
+	t.input.send(Event{Type: Resume, Width: w, Height: h})
+	return err
+}
+
+// whether Exec is running a program
+func (t *Terminal) running() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.execing
+}
+
+// sets everything up again after a Suspend
+func (t *Terminal) resume() error {
+	t.mu.Lock()
+	if t.closed || !t.suspended || t.execing {
+		t.mu.Unlock()
+		return nil
+	}
+	t.suspended = false
+	err := t.apply()
+	t.input.resume()
+	t.mu.Unlock()
+
+	w, h := TermSize(int(t.Out.Fd()))
+	t.input.send(Event{Type: Resume, Width: w, Height: h})
+	return err
+}
+
+// undoes every change, most recent first
+func (t *Terminal) reset() error {
+	var err error
+	for i := len(t.modes) - 1; i >= 0; i-- {
+		if e := t.modes[i].reset(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// makes every change again, in the original order
+func (t *Terminal) apply() error {
+	for _, m := range t.modes {
+		if err := m.set(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Closes the Terminal if the calling goroutine is panicking, then carries on panicking.
+// Use as defer t.Recover()
+func (t *Terminal) Recover() {
+	if r := recover(); r != nil {
+		t.Close()
+		panic(r)
+	}
+}
+
+// applies a change to the terminal, recording how to undo it
+func (t *Terminal) set(set, reset func() error) error {
+	if err := set(); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.modes = append(t.modes, mode{set, reset})
+	t.mu.Unlock()
+	return nil
+}
+
+// for changes that are just output, and can't fail
+func (t *Terminal) setOutput(set, reset func()) {
+	t.set(func() error { set(); return nil }, func() error { reset(); return nil })
+}
+
+/*
+Deals with signals that would otherwise leave the terminal in a mess:
+  - puts the terminal back before the program is killed, then lets the signal do what it would have
+  - suspends on SIGTSTP, and sets up again on SIGCONT
+
+While Exec runs a program, the terminal is already handed over. Ctrl-C is the program's to deal with,
+and Ctrl-Z just has to stop us along with it
+*/
+func (t *Terminal) handleSignals() {
+	for {
+		select {
+		case sig := <-t.sigs:
+			switch {
+			case sig == sigStop && t.running():
+				stopProcess()
+			case sig == sigStop:
+				t.Suspend()
+			case sig == sigCont:
+				t.resume()
+			case sig == os.Interrupt && t.running():
+				// the program got it too
+			default:
+				t.Close()
+				signal.Reset(sig)
+				raise(sig)
+				return
+			}
+		case <-t.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,596 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pzl/tui/ansi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh/terminal"
+	"golang.org/x/sys/unix"
+)
+
+// a pseudo terminal. The test plays the part of the terminal emulator on the master side
+type pty struct {
+	master *os.File
+	tty    *os.File
+
+	mu  sync.Mutex
+	out strings.Builder // everything the program wrote to the tty
+}
+
+func openPty(t *testing.T) *pty {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skip("no pty:", err)
+	}
+	n, err := unix.IoctlGetInt(int(m.Fd()), unix.TIOCGPTN)
+	require.NoError(t, err)
+	require.NoError(t, unix.IoctlSetPointerInt(int(m.Fd()), unix.TIOCSPTLCK, 0))
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|unix.O_NOCTTY, 0)
+	require.NoError(t, err)
+
+	p := &pty{master: m, tty: tty}
+	go func() {
+		b := make([]byte, 256)
+		for {
+			n, err := m.Read(b)
+			p.mu.Lock()
+			p.out.Write(b[:n])
+			p.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p
+}
+
+func (p *pty) close() {
+	p.tty.Close()
+	p.master.Close()
+}
+
+// waits for the program's output to contain want, then returns and forgets everything written so far
+func (p *pty) expect(t *testing.T, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		p.mu.Lock()
+		s := p.out.String()
+		if strings.Contains(s, want) || time.Now().After(deadline) {
+			p.out.Reset()
+			p.mu.Unlock()
+			assert.Contains(t, s, want)
+			return s
+		}
+		p.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func nextEvent(t *testing.T, ch <-chan Event) Event {
+	t.Helper()
+	select {
+	case ev, ok := <-ch:
+		require.True(t, ok, "events closed")
+		return ev
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+	}
+	return Event{}
+}
+
+func TestTerminal(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	fd := int(p.tty.Fd())
+	before, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	require.NoError(t, err)
+
+	term, err := Open(Files(p.tty, p.tty), AltScreen(), MouseReporting(ansi.MotionNone), BracketedPaste(), HideCursor())
+	require.NoError(t, err)
+	p.expect(t, "\x1b[?1049h\x1b[?1000h\x1b[?1006h\x1b[?2004h\x1b[?25l")
+
+	raw, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	require.NoError(t, err)
+	assert.Zero(t, raw.Lflag&unix.ICANON, "raw mode")
+
+	p.master.Write([]byte("a"))
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'a'}, nextEvent(t, term.Events()))
+
+	require.NoError(t, term.Close())
+	p.expect(t, "\x1b[?25h\x1b[?2004l\x1b[?1006l\x1b[?1000l\x1b[?1049l")
+
+	after, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	require.NoError(t, err)
+	assert.Equal(t, before, after, "terminal restored")
+	_, ok := <-term.Events()
+	assert.False(t, ok, "events closed")
+
+	assert.NoError(t, term.Close(), "closing twice is fine")
+}
+
+func TestTerminal_Recover(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	term, err := Open(Files(p.tty, p.tty), AltScreen())
+	require.NoError(t, err)
+	p.expect(t, "\x1b[?1049h")
+
+	assert.Panics(t, func() {
+		defer term.Recover()
+		panic("oops")
+	})
+	p.expect(t, "\x1b[?1049l")
+	assert.True(t, terminal.IsTerminal(int(p.tty.Fd())))
+	_, ok := <-term.Events()
+	assert.False(t, ok, "closed on panic")
+}
+
+// runs in a child process, in its own process group, so that stopping it doesn't stop the tests
+func TestTerminal_Suspend(t *testing.T) {
+	if os.Getenv("TUI_TEST_SUSPEND") == "1" {
+		tty := os.NewFile(3, "tty")
+		term, err := Open(Files(tty, tty), AltScreen())
+		if err != nil {
+			os.Exit(2)
+		}
+		term.Suspend()
+		for ev := range term.Events() {
+			if ev.Type == Resume {
+				fmt.Fprint(tty, "resumed")
+				term.Close()
+				os.Exit(0)
+			}
+		}
+		os.Exit(3)
+	}
+
+	p := openPty(t)
+	defer p.close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTerminal_Suspend$")
+	cmd.Env = append(os.Environ(), "TUI_TEST_SUSPEND=1")
+	cmd.ExtraFiles = []*os.File{p.tty}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+
+	var ws syscall.WaitStatus
+	_, err := syscall.Wait4(cmd.Process.Pid, &ws, syscall.WUNTRACED, nil)
+	require.NoError(t, err)
+	require.True(t, ws.Stopped(), "child stopped")
+	assert.Equal(t, syscall.SIGSTOP, ws.StopSignal())
+
+	p.expect(t, "\x1b[?1049h\x1b[?1049l")
+	cooked, err := unix.IoctlGetTermios(int(p.tty.Fd()), unix.TCGETS)
+	require.NoError(t, err)
+	assert.NotZero(t, cooked.Lflag&unix.ICANON, "cooked mode while suspended")
+
+	require.NoError(t, cmd.Process.Signal(syscall.SIGCONT))
+	p.expect(t, "\x1b[?1049hresumed")
+	assert.NoError(t, cmd.Wait())
+}
+
+// the same, for input from GetInput
+func TestSuspend(t *testing.T) {
+	if os.Getenv("TUI_TEST_SUSPEND_INPUT") == "1" {
+		tty := os.NewFile(3, "tty")
+		events, restore, err := GetInput(nil, 3)
+		if err != nil {
+			os.Exit(2)
+		}
+		Suspend()
+		for ev := range events {
+			if ev.Type == Resume {
+				fmt.Fprint(tty, "resumed")
+				restore()
+				os.Exit(0)
+			}
+		}
+		os.Exit(3)
+	}
+
+	p := openPty(t)
+	defer p.close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSuspend$")
+	cmd.Env = append(os.Environ(), "TUI_TEST_SUSPEND_INPUT=1")
+	cmd.ExtraFiles = []*os.File{p.tty}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+
+	var ws syscall.WaitStatus
+	_, err := syscall.Wait4(cmd.Process.Pid, &ws, syscall.WUNTRACED, nil)
+	require.NoError(t, err)
+	require.True(t, ws.Stopped(), "child stopped")
+
+	cooked, err := unix.IoctlGetTermios(int(p.tty.Fd()), unix.TCGETS)
+	require.NoError(t, err)
+	assert.NotZero(t, cooked.Lflag&unix.ICANON, "cooked mode while suspended")
+
+	require.NoError(t, cmd.Process.Signal(syscall.SIGCONT))
+	p.expect(t, "resumed")
+	assert.NoError(t, cmd.Wait())
+}
+
+func TestTerminal_Exec(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	term, err := Open(Files(p.tty, p.tty), AltScreen())
+	require.NoError(t, err)
+	defer term.Close()
+	p.expect(t, "\x1b[?1049h")
+
+	done := make(chan error)
+	go func() { done <- term.Exec(exec.Command("sh", "-c", `read l; echo "got:$l"`)) }()
+
+	p.expect(t, "\x1b[?1049l")
+	p.master.Write([]byte("hello\n"))
+	require.NoError(t, <-done)
+	out := p.expect(t, "\x1b[?1049h")
+	assert.Contains(t, out, "got:hello", "the program gets the input, not the Terminal")
+
+	assert.Equal(t, Resume, nextEvent(t, term.Events()).Type)
+	p.master.Write([]byte("a"))
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'a'}, nextEvent(t, term.Events()))
+}
+
+func TestExec(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	events, restore, err := GetInput(nil, int(p.tty.Fd()))
+	require.NoError(t, err)
+	defer restore()
+
+	cmd := exec.Command("sh", "-c", `stty -a | grep -q ' icanon' && echo cooked; read l; echo "got:$l"`)
+	cmd.Stdin, cmd.Stdout = p.tty, p.tty
+	done := make(chan error)
+	go func() { done <- Exec(cmd) }()
+
+	p.expect(t, "cooked")
+	p.master.Write([]byte("hello\n"))
+	require.NoError(t, <-done)
+	p.expect(t, "got:hello")
+
+	assert.Equal(t, Resume, nextEvent(t, events).Type)
+	raw, err := unix.IoctlGetTermios(int(p.tty.Fd()), unix.TCGETS)
+	require.NoError(t, err)
+	assert.Zero(t, raw.Lflag&unix.ICANON, "raw mode again")
+	p.master.Write([]byte("a"))
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'a'}, nextEvent(t, events))
+}
+
+func TestGetInput_Modes(t *testing.T) {
+	tests := map[string]struct {
+		opts      []Option
+		on, off   uint32 // Lflag
+		opost     bool
+		onI, offI uint32 // Iflag
+	}{
+		"raw":    {off: unix.ICANON | unix.ECHO | unix.ISIG, offI: unix.ICRNL | unix.IXON},
+		"cbreak": {opts: []Option{InputMode(Cbreak)}, on: unix.ISIG, off: unix.ICANON | unix.ECHO, opost: true, onI: unix.IXON, offI: unix.ICRNL},
+		"custom": {
+			opts: []Option{InputMode(Cbreak), TermiosFlags(TermFlags{Lflag: unix.ISIG}, TermFlags{Iflag: unix.ICRNL})},
+			off:  unix.ICANON | unix.ISIG, opost: true, onI: unix.ICRNL,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := openPty(t)
+			defer p.close()
+			fd := int(p.tty.Fd())
+			before, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+			require.NoError(t, err)
+
+			_, restore, err := GetInput(nil, fd, tc.opts...)
+			require.NoError(t, err)
+			tio, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+			require.NoError(t, err)
+			require.NoError(t, restore())
+
+			assert.Equal(t, tc.on, tio.Lflag&tc.on)
+			assert.Zero(t, tio.Lflag&tc.off)
+			assert.Equal(t, tc.onI, tio.Iflag&tc.onI)
+			assert.Zero(t, tio.Iflag&tc.offI)
+			assert.Equal(t, tc.opost, tio.Oflag&unix.OPOST != 0)
+
+			after, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+			require.NoError(t, err)
+			assert.Equal(t, before, after)
+		})
+	}
+}
+
+func TestOpen_NotATerminal(t *testing.T) {
+	if tty, err := OpenTTY(); err == nil {
+		tty.Close()
+		t.Skip("there's a controlling terminal, Open would use it")
+	}
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	_, err = Open(Files(r, w))
+	assert.Error(t, err)
+}
+
+// stdin and stdout are pipes, in a child process whose controlling terminal is the pty
+func TestOpen_ControllingTerminal(t *testing.T) {
+	if os.Getenv("TUI_TEST_CTTY") == "1" {
+		w, h := TermSize(int(os.Stdout.Fd()))
+		fmt.Printf("%dx%d\n", w, h)
+		term, err := Open(AltScreen())
+		if err != nil {
+			os.Exit(2)
+		}
+		ev := <-term.Events()
+		term.Close()
+		fmt.Printf("%c\n", ev.Key)
+		os.Exit(0)
+	}
+
+	p := openPty(t)
+	defer p.close()
+	require.NoError(t, unix.IoctlSetWinsize(int(p.tty.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Row: 30, Col: 100}))
+
+	var out strings.Builder
+	cmd := exec.Command(os.Args[0], "-test.run=^TestOpen_ControllingTerminal$")
+	cmd.Env = append(os.Environ(), "TUI_TEST_CTTY=1")
+	cmd.Stdin = strings.NewReader("data to filter")
+	cmd.Stdout = &out
+	cmd.ExtraFiles = []*os.File{p.tty}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true, Ctty: 3}
+	require.NoError(t, cmd.Start())
+
+	p.expect(t, "\x1b[?1049h")
+	p.master.Write([]byte("x"))
+	require.NoError(t, cmd.Wait())
+	p.expect(t, "\x1b[?1049l")
+	assert.Equal(t, "100x30\nx\n", out.String())
+}
+
+func TestTerminal_CursorPosition(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	term, err := Open(Files(p.tty, p.tty))
+	require.NoError(t, err)
+	defer term.Close()
+
+	type pos struct{ row, col int }
+	got := make(chan pos)
+	go func() {
+		row, col, err := term.CursorPosition()
+		assert.NoError(t, err)
+		got <- pos{row, col}
+	}()
+
+	p.expect(t, "\x1b[6n")
+	p.master.Write([]byte("a\x1b[1;2Rb")) // row 1 looks like Shift-F3
+	assert.Equal(t, pos{1, 2}, <-got)
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'a'}, nextEvent(t, term.Events()), "other input still arrives")
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'b'}, nextEvent(t, term.Events()))
+
+	_, err = term.Query("\x1b[c", func(string) bool { return true }, 10*time.Millisecond)
+	assert.Equal(t, ErrNoReply, err)
+
+	// a reply nobody is waiting for anymore
+	p.master.Write([]byte("\x1b[?62c"))
+	assert.Equal(t, Event{Type: Reply, Text: "\x1b[?62c"}, nextEvent(t, term.Events()))
+}
+
+func TestCursorPosition(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+
+	go func() {
+		p.expect(t, "\x1b[6n")
+		p.master.Write([]byte("\x1b[12;40R"))
+	}()
+	row, col, err := CursorPosition(int(p.tty.Fd()))
+	require.NoError(t, err)
+	assert.Equal(t, 12, row)
+	assert.Equal(t, 40, col)
+}
+
+func TestCursorPosition_GetInput(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	fd := int(p.tty.Fd())
+	events, restore, err := GetInput(nil, fd)
+	require.NoError(t, err)
+	defer restore()
+
+	go func() {
+		p.expect(t, "\x1b[6n")
+		p.master.Write([]byte("\x1b[3;4Rz"))
+	}()
+	row, col, err := CursorPosition(fd)
+	require.NoError(t, err)
+	assert.Equal(t, 3, row)
+	assert.Equal(t, 4, col)
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'z'}, nextEvent(t, events))
+}
+
+// stdin is a pipe, so input is read from the controlling terminal in its place. The query has to go there too
+func TestCursorPosition_Pipe(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	fd := int(p.tty.Fd())
+	o := newOptions(nil)
+	o.ctty = true // as GetInput does, had it opened the pty as the controlling terminal
+	require.NoError(t, setMode(fd, o))
+	in, err := startInput(context.Background(), fd, o)
+	require.NoError(t, err)
+	defer in.stop()
+
+	go func() {
+		p.expect(t, "\x1b[6n")
+		p.master.Write([]byte("\x1b[3;4Rz"))
+	}()
+	row, col, err := CursorPosition(int(r.Fd()))
+	require.NoError(t, err)
+	assert.Equal(t, 3, row)
+	assert.Equal(t, 4, col)
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'z'}, nextEvent(t, in.ch))
+}
+
+// a report that never came isn't expected anymore, so the next Shift-F3 (CSI 1;2R) is the key
+func TestCursorPosition_Timeout(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	fd := int(p.tty.Fd())
+	events, restore, err := GetInput(nil, fd)
+	require.NoError(t, err)
+	defer restore()
+
+	_, err = Query(fd, ansi.CursorPosition.String(), isCursorReply, 20*time.Millisecond)
+	assert.Equal(t, ErrNoReply, err)
+	p.expect(t, "\x1b[6n")
+	p.master.Write([]byte("\x1b[1;2R"))
+	assert.Equal(t, key(F3, ModShift), nextEvent(t, events))
+
+	// other input while waiting
+	go func() {
+		p.expect(t, "\x1b[6n")
+		p.master.Write([]byte("a"))
+	}()
+	_, err = Query(fd, ansi.CursorPosition.String(), isCursorReply, 50*time.Millisecond)
+	assert.Equal(t, ErrNoReply, err)
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'a'}, nextEvent(t, events))
+	p.master.Write([]byte("\x1b[1;2R"))
+	assert.Equal(t, key(F3, ModShift), nextEvent(t, events))
+}
+
+func TestGetInput_Resize(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	events, restore, err := GetInput(nil, int(p.tty.Fd()))
+	require.NoError(t, err)
+	defer restore()
+
+	require.NoError(t, unix.IoctlSetWinsize(int(p.master.Fd()), unix.TIOCSWINSZ, &unix.Winsize{Col: 132, Row: 43}))
+	// the pty isn't our controlling terminal, so the kernel won't send SIGWINCH. Send it ourselves,
+	// until the watcher has started listening for it
+	deadline := time.After(time.Second)
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGWINCH)
+		select {
+		case ev := <-events:
+			assert.Equal(t, Event{Type: Resize, Width: 132, Height: 43}, ev)
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no Resize event")
+		}
+	}
+}
+
+func TestTerminal_Detect(t *testing.T) {
+	defer setenv(map[string]string{"TERM": "xterm-256color", "COLORTERM": "", "TERM_PROGRAM": "", "VTE_VERSION": ""})()
+	p := openPty(t)
+	defer p.close()
+	term, err := Open(Files(p.tty, p.tty))
+	require.NoError(t, err)
+	defer term.Close()
+
+	go func() {
+		p.expect(t, "\x1b[c")
+		p.master.Write([]byte("\x1bP>|kitty(0.26.5)\x1b\\" +
+			"\x1b[?1u" +
+			"\x1b_Gi=31;OK\x1b\\" +
+			"\x1b[?1006;2$y\x1b[?1004;2$y\x1b[?2004;2$y\x1b[?2026;0$y" +
+			"\x1b[?62;4;22c" +
+			"x"))
+	}()
+	c := term.Detect()
+	assert.Equal(t, Capabilities{
+		Name:           "kitty",
+		Version:        "0.26.5",
+		Colors:         ColorsTrueColor,
+		KittyKeyboard:  true,
+		KittyGraphics:  true,
+		Sixel:          true,
+		SGRMouse:       true,
+		BracketedPaste: true,
+		FocusEvents:    true,
+		Hyperlinks:     true,
+		Replied:        true,
+	}, c)
+	assert.Equal(t, Event{Type: KeyPrint, Key: 'x'}, nextEvent(t, term.Events()), "replies don't show up as events")
+}
+
+func TestTerminal_Colors(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	term, err := Open(Files(p.tty, p.tty))
+	require.NoError(t, err)
+	defer term.Close()
+
+	go func() {
+		p.expect(t, "\x1b]11;?\x1b\\\x1b[c")
+		p.master.Write([]byte("\x1b]11;rgb:fdfd/f6f6/e3e3\x1b\\\x1b[?62;22c"))
+	}()
+	bg, err := term.BackgroundColor()
+	require.NoError(t, err)
+	assert.Equal(t, ansi.T(0xfd, 0xf6, 0xe3), bg)
+
+	go func() {
+		p.expect(t, "\x1b]4;1;?\x1b\\\x1b[c")
+		p.master.Write([]byte("\x1b]4;1;rgb:cc/00/00\a\x1b[?62;22c"))
+	}()
+	red, err := term.PaletteColor(1)
+	require.NoError(t, err)
+	assert.Equal(t, ansi.T(0xcc, 0, 0), red)
+
+	// no answer but DA1: the terminal doesn't know the query, no need to wait out the timeout
+	go func() {
+		p.expect(t, "\x1b]10;?\x1b\\\x1b[c")
+		p.master.Write([]byte("\x1b[?62;22c"))
+	}()
+	start := time.Now()
+	_, err = term.ForegroundColor()
+	assert.Equal(t, ErrNoReply, err)
+	assert.True(t, time.Since(start) < queryTimeout)
+}
+
+func TestTerminal_Clipboard(t *testing.T) {
+	p := openPty(t)
+	defer p.close()
+	term, err := Open(Files(p.tty, p.tty))
+	require.NoError(t, err)
+	defer term.Close()
+
+	term.SetClipboard(ansi.Clipboard, "hi")
+	p.expect(t, "\x1b]52;c;aGk=\x1b\\")
+
+	go func() {
+		p.expect(t, "\x1b]52;c;?\x1b\\\x1b[c")
+		p.master.Write([]byte("\x1b]52;c;aGVsbG8=\a\x1b[?62;22c"))
+	}()
+	s, err := term.GetClipboard(ansi.Clipboard)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", s)
+
+	go func() {
+		p.expect(t, "\x1b]52;p;?\x1b\\\x1b[c")
+		p.master.Write([]byte("\x1b]52;p;\x1b\\\x1b[?62;22c"))
+	}()
+	s, err = term.GetClipboard(ansi.Primary)
+	require.NoError(t, err)
+	assert.Equal(t, "", s, "empty, rather than no reply")
+}
@@ -0,0 +1,12 @@
+//go:build linux || aix || solaris
+
+package tui
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlGetTermios = unix.TCGETS
+	ioctlSetTermios = unix.TCSETS
+)
+
+type tcflag = uint32
@@ -1,8 +1,6 @@
 package tui
 
 import (
-	"errors"
-	"fmt"
 	"os"
 	"strconv"
 
@@ -12,7 +10,8 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 )
 
-// width and height of the terminal. Falls back to defaults (124,80) if it can't be determined
+// width and height of the terminal. If fd isn't a terminal, the controlling terminal's size is used.
+// Falls back to $COLUMNS and $LINES, then defaults (124,80) if it can't be determined
 //
 // fd should be where you intend to print to. For Stdout: int(os.Stdout.Fd())
 func TermSize(fd int) (int, int) {
@@ -20,6 +19,9 @@ func TermSize(fd int) (int, int) {
 	const defaultHeight = 80
 
 	w, h, err := terminal.GetSize(fd)
+	if err != nil {
+		w, h, err = ttySize()
+	}
 	if err != nil {
 		w = env("COLUMNS", defaultWidth)
 		h = env("LINES", defaultHeight)
@@ -41,51 +43,44 @@ func atoi(s string, def int) int {
 	return def
 }
 
-func IsTTY(fd uintptr) bool { return isatty.IsTerminal(fd) }
-func RuneWidth(r rune) int  { return runewidth.RuneWidth(r) }
-
-// Returns the coordinates of the cursor. fd should almost always be 0 for stdin
-// Or you can use int(os.Stdin.Fd())
-func CursorPosition(fd int) (x int, y int, err error) {
-	if !IsTTY(uintptr(fd)) {
-		return 0, 0, errors.New("input is not a TTY")
-	}
-	state, err := terminal.GetState(fd)
+func ttySize() (int, int, error) {
+	tty, err := OpenTTY()
 	if err != nil {
-		return 0, 0, fmt.Errorf("error getting terminal state: %v", err)
+		return 0, 0, err
 	}
-	defer func() {
-		err = terminal.Restore(fd, state)
-	}()
+	defer tty.Close()
+	return terminal.GetSize(int(tty.Fd()))
+}
 
-	err = setNonBlock(fd, false)
-	if err != nil {
-		return 0, 0, fmt.Errorf("error setting terminal as blocking: %v", err)
-	}
-	_, err = terminal.MakeRaw(fd)
-	if err != nil {
-		return 0, 0, fmt.Errorf("error putting terminal in raw mode: %v", err)
-	}
+func IsTTY(fd uintptr) bool { return isatty.IsTerminal(fd) }
 
-	// prints 6n to stderr
-	ansi.NewWriter(nil).CursorPosition()
+/*
+Opens the controlling terminal (/dev/tty), for reading and writing. That's where the user is, even when
+stdin or stdout are redirected: think of fzf, reading the list to filter on stdin, and keys from the terminal.
 
-	//@todo: probably read char-by-char.
-	// a random, blocking, 13-byte buffer is odd
+GetInput, Open and TermSize already switch to it when they're given something that isn't a terminal
+*/
+func OpenTTY() (*os.File, error) { return os.OpenFile(ttyPath, os.O_RDWR, 0) }
 
-	// read response from stdin
-	b := make([]byte, 13)
-	_, err = sysRead(fd, b)
-	if err != nil {
-		return 0, 0, fmt.Errorf("error reading input: %v", err)
+// f if it's a terminal, or else the controlling terminal. opened is set when the
+// controlling terminal was opened, and should be closed when done
+func ttyOr(f *os.File) (tty *os.File, opened bool) {
+	if IsTTY(f.Fd()) {
+		return f, false
 	}
-
-	n, err := fmt.Sscanf(string(b), "\x1b[%d;%dR", &x, &y)
+	tty, err := OpenTTY()
 	if err != nil {
-		return 0, 0, fmt.Errorf("error parsing terminal response: %v", err)
-	}
-	if n < 2 {
-		return 0, 0, fmt.Errorf("error parsing cursor position: %v", err)
+		return f, false // let the caller find out it's not a terminal
 	}
-	return x, y, nil
+	return tty, true
+}
+
+func RuneWidth(r rune) int { return runewidth.RuneWidth(r) }
+
+// Returns the coordinates of the cursor, 1-based: x is the row, y the column. fd should almost always be 0 for stdin
+// Or you can use int(os.Stdin.Fd())
+//
+// Safe to call while GetInput is reading fd, the reply is picked out of the input (see Query)
+func CursorPosition(fd int) (x int, y int, err error) {
+	return cursorXY(Query(fd, ansi.CursorPosition.String(), isCursorReply, queryTimeout))
 }